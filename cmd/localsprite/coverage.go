@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/coverage"
+	"localsprite/internal/repocontext"
+)
+
+// coverageReport compares the coverage of each target's passing run with
+// that of the project's own tests, which are run once, on the first target
+// that measured coverage.
+type coverageReport struct {
+	repo     *repocontext.Repo
+	baseline []coverage.Function
+	measured bool
+
+	// gains are the statements newly covered by each target's tests
+	gains map[string]int
+}
+
+// add reports the coverage gained by the tests of target file. Runs
+// without coverage are ignored. Failing to measure coverage only costs the
+// report, unless the run was cancelled.
+func (c *coverageReport) add(ctx context.Context, a *agent.Agent, file string, result *agent.ExecutionResult) error {
+	if result == nil || result.Coverage == nil {
+		return nil
+	}
+	if err := c.measure(ctx, a, file, result); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		fmt.Printf("[LocalSprite] Coverage of %s not reported: %v\n", targetName(file), err)
+	}
+	return nil
+}
+
+func (c *coverageReport) measure(ctx context.Context, a *agent.Agent, file string, result *agent.ExecutionResult) error {
+	if !c.measured {
+		fmt.Printf("[LocalSprite] Measuring baseline coverage of the existing tests...\n")
+		baseline, err := a.Baseline(ctx)
+		if err != nil {
+			return err
+		}
+		if baseline.Coverage == nil {
+			fmt.Printf("[LocalSprite] Baseline run produced no coverage profile, comparing against no coverage\n")
+		}
+		if c.baseline, err = coverage.Functions(c.repo, baseline.Coverage); err != nil {
+			return err
+		}
+		c.measured = true
+	}
+
+	after, err := coverage.Functions(c.repo, result.Coverage)
+	if err != nil {
+		return err
+	}
+	report := coverage.Report(c.baseline, after)
+	fmt.Printf("[LocalSprite] Coverage of %s:\n  %s\n", targetName(file), strings.ReplaceAll(report, "\n", "\n  "))
+
+	if c.gains == nil {
+		c.gains = map[string]int{}
+	}
+	for _, change := range coverage.Compare(c.baseline, after) {
+		c.gains[file] += change.Gain()
+	}
+	return nil
+}
+
+// rank prints the targets by the statements their generated tests newly
+// covered together, when more than one target measured coverage. Test
+// functions are not ranked on their own, as each target's tests run in one
+// profile.
+func (c *coverageReport) rank() {
+	if len(c.gains) < 2 {
+		return
+	}
+	files := make([]string, 0, len(c.gains))
+	for file := range c.gains {
+		files = append(files, file)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if c.gains[files[i]] != c.gains[files[j]] {
+			return c.gains[files[i]] > c.gains[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	fmt.Printf("[LocalSprite] Statements newly covered by target:\n")
+	for _, file := range files {
+		fmt.Printf("  %+5d  %s\n", c.gains[file], targetName(file))
+	}
+}
+
+func targetName(file string) string {
+	if file == "" {
+		return "the generated tests"
+	}
+	return file
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/config"
+	"localsprite/internal/coverage"
+	"localsprite/internal/mutation"
+	"localsprite/internal/registry"
+	"localsprite/internal/repocontext"
+	"localsprite/pkg/providers/coder"
+)
+
+func main() {
+	// Ctrl-C and SIGTERM cancel in-flight LLM calls and stop running containers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "localsprite: interrupted")
+			os.Exit(130)
+		}
+		fmt.Fprintf(os.Stderr, "localsprite: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("localsprite", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "path to the profile configuration file")
+	profileName := fs.String("profile", "work", "profile to run (see config.yaml)")
+	repoPath := fs.String("repo", ".", "path to the target repository")
+	targetFile := fs.String("target", "", "file within the repository to generate tests for")
+	functions := fs.String("function", "", "comma-separated functions or methods (Type.Method) of a Go target to focus on (default: its untested exported functions)")
+	base := fs.String("base", "", "git ref to diff against; generate tests only for code changed since its merge base with HEAD")
+	maxIterations := fs.Int("max-iterations", 0, "maximum generate/repair attempts (overrides the profile)")
+	timeout := fs.Duration("timeout", 0, "overall run deadline, e.g. 15m (overrides the profile)")
+	contextTokens := fs.Int("context-tokens", 0, "token budget of the repository context sent to the planner (overrides the profile)")
+	planIn := fs.String("plan", "", "run a stored test plan (JSON) instead of calling the planner")
+	planOut := fs.String("plan-out", "", "write the test plan as JSON to this file")
+	planOnly := fs.Bool("plan-only", false, "stop after planning, e.g. to review or edit the plan")
+	write := fs.Bool("write", false, "write passing tests into the repository")
+	overwrite := fs.Bool("overwrite", false, "let --write replace existing files")
+	diffOut := fs.String("diff", "", "write passing tests as a unified diff against HEAD to this file")
+	patchOut := fs.String("patch", "", "write passing tests as a git format-patch file")
+	branch := fs.String("branch", "", "commit passing tests to this new local branch, without checking it out")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *base != "" && (*targetFile != "" || *functions != "" || *planIn != "" || *planOut != "") {
+		return errors.New("--base selects its own targets and cannot be combined with --target, --function, --plan or --plan-out")
+	}
+	if *planOnly && (*write || *diffOut != "" || *patchOut != "" || *branch != "") {
+		return errors.New("--plan-only generates no tests to --write, --diff, --patch or --branch")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	profile, err := cfg.GetProfile(*profileName)
+	if err != nil {
+		return err
+	}
+
+	a, err := registry.Default().BuildAgent(profile)
+	if err != nil {
+		return fmt.Errorf("profile %q: %w", *profileName, err)
+	}
+	if *maxIterations > 0 {
+		a.MaxIterations = *maxIterations
+	}
+
+	repo, err := filepath.Abs(*repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve repo path: %w", err)
+	}
+	if info, err := os.Stat(repo); err != nil {
+		return fmt.Errorf("failed to open repo: %w", err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("repo path %s is not a directory", repo)
+	}
+
+	fmt.Printf("[LocalSprite] Running profile %q against %s\n", *profileName, repo)
+
+	opts := runOptions{
+		budget:     profile.Agent.ContextTokens,
+		planOut:    *planOut,
+		planOnly:   *planOnly,
+		maxMutants: profile.Agent.MaxMutants,
+	}
+	if *contextTokens > 0 {
+		opts.budget = *contextTokens
+	}
+	if opts.budget <= 0 {
+		opts.budget = repocontext.DefaultTokenBudget
+	}
+
+	runTimeout := profile.Agent.RunTimeout
+	if *timeout > 0 {
+		runTimeout = *timeout
+	}
+	if runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runTimeout)
+		defer cancel()
+	}
+
+	repoInfo, err := repocontext.Analyze(repo)
+	if err != nil {
+		return fmt.Errorf("failed to analyze repo: %w", err)
+	}
+
+	var plan *agent.TestPlan
+	if *planIn != "" {
+		data, err := os.ReadFile(*planIn)
+		if err != nil {
+			return fmt.Errorf("failed to read plan: %w", err)
+		}
+		if plan, err = agent.ParseTestPlan(data); err != nil {
+			return fmt.Errorf("%s: %w", *planIn, err)
+		}
+
+		// A stored plan is written or printed whole, not once per target
+		// it is split into
+		if *planOut != "" {
+			if err := writePlan(*planOut, plan); err != nil {
+				return err
+			}
+			fmt.Printf("[LocalSprite] Wrote test plan to %s\n", *planOut)
+			opts.planOut = ""
+		} else if *planOnly {
+			fmt.Print(plan)
+		}
+		if *planOnly {
+			return nil
+		}
+	}
+
+	var targets []target
+	switch {
+	case *base != "":
+		if targets, err = changedTargets(ctx, repoInfo, *base); err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			fmt.Printf("[LocalSprite] No changed source code since %s, nothing to test\n", *base)
+			return nil
+		}
+	case plan != nil && *targetFile == "":
+		// Each target file of the plan gets its own coder call
+		if targets, err = planTargets(repoInfo, plan); err != nil {
+			return err
+		}
+	default:
+		t, err := fileTarget(repoInfo, *targetFile, *functions)
+		if err != nil {
+			return err
+		}
+		t.plan = plan
+		targets = []target{t}
+	}
+
+	if u, ok := a.Coder.(interface{ Usage() coder.TokenUsage }); ok {
+		defer func() {
+			usage := u.Usage()
+			fmt.Printf("[LocalSprite] Coder token usage: %d input, %d output\n", usage.InputTokens, usage.OutputTokens)
+		}()
+	}
+
+	out := outputOptions{
+		write:     *write,
+		overwrite: *overwrite,
+		diff:      *diffOut,
+		patch:     *patchOut,
+		branch:    *branch,
+	}
+
+	cov := &coverageReport{repo: repoInfo}
+
+	if len(targets) == 1 {
+		result, err := runTarget(ctx, a, repoInfo, targets[0], opts)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return nil
+		}
+		if err := cov.add(ctx, a, targets[0].file, result); err != nil {
+			return err
+		}
+		return deliver(ctx, repo, result.Files, []string{targets[0].file}, out)
+	}
+
+	// Keep going after a failing target so that one run reports on the whole
+	// change, unless the run itself was cancelled. Tests of the targets that
+	// passed are still delivered.
+	var (
+		failed, passed []string
+		files          []agent.Artifact
+	)
+	for i, t := range targets {
+		fmt.Printf("[LocalSprite] Target %d/%d: %s\n", i+1, len(targets), t.file)
+		result, err := runTarget(ctx, a, repoInfo, t, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			fmt.Printf("[LocalSprite] %s: %v\n", t.file, err)
+			failed = append(failed, t.file)
+			continue
+		}
+		if result != nil {
+			merged, err := mergeFiles(files, result.Files)
+			if err != nil {
+				fmt.Printf("[LocalSprite] %s: %v\n", t.file, err)
+				failed = append(failed, t.file)
+				continue
+			}
+			files = merged
+			passed = append(passed, t.file)
+			if err := cov.add(ctx, a, t.file, result); err != nil {
+				return err
+			}
+		}
+	}
+	cov.rank()
+
+	// The tests of each target passed on their own; together they may still
+	// clash, e.g. on test names in a shared package
+	if len(passed) > 1 {
+		fmt.Printf("[LocalSprite] Running the tests of %d targets together\n", len(passed))
+		a.Workspace = agent.Workspace{SourceDir: repo}
+		result, err := a.Check(ctx, files)
+		if err != nil {
+			return err
+		}
+		if !result.Passed() {
+			fmt.Print(result.Feedback())
+			return fmt.Errorf("the tests of %s fail when run together", strings.Join(passed, ", "))
+		}
+	}
+	if err := deliver(ctx, repo, files, passed, out); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d target(s) failed: %s", len(failed), len(targets), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// runOptions are the settings shared by every target of a run.
+type runOptions struct {
+	budget   int
+	planOut  string
+	planOnly bool
+
+	// maxMutants bounds the mutants tested per target, if set
+	maxMutants int
+}
+
+// runTarget plans (or takes the stored plan of) a single target, then
+// generates, executes and repairs its tests. It returns the passing run, or
+// nil when only planning was requested.
+func runTarget(ctx context.Context, a *agent.Agent, repo *repocontext.Repo, t target, opts runOptions) (*agent.ExecutionResult, error) {
+	plan := t.plan
+	if plan == nil {
+		// The change and the focused functions take priority over the rest
+		// of the repository.
+		var extra string
+		if t.changes != "" {
+			extra += "\n" + t.changes
+		}
+		if t.focus != "" {
+			extra += "\n## Functions under test\n\n" + t.focus
+		}
+		const minRepoTokens = 1000
+		repoBudget := max(opts.budget-repocontext.EstimateTokens(extra), minRepoTokens)
+		repoContext := repo.Render(repocontext.Options{Target: t.file, TokenBudget: repoBudget}) + extra
+		fmt.Printf("[LocalSprite] Repository context: ~%d tokens\n", repocontext.EstimateTokens(repoContext))
+
+		var err error
+		if plan, err = a.Plan(ctx, repoContext); err != nil {
+			return nil, fmt.Errorf("planning failed: %w", err)
+		}
+	}
+	fmt.Printf("[LocalSprite] Test plan has %d scenario(s)\n", len(plan.Scenarios))
+
+	if opts.planOut != "" {
+		if err := writePlan(opts.planOut, plan); err != nil {
+			return nil, err
+		}
+		fmt.Printf("[LocalSprite] Wrote test plan to %s\n", opts.planOut)
+	}
+	if opts.planOnly {
+		if opts.planOut == "" {
+			fmt.Print(plan)
+		}
+		return nil, nil
+	}
+
+	// The generated tests run in a copy of the repo; Go tests join the
+	// target's package, others are left to the runner's test discovery
+	a.Workspace = agent.Workspace{SourceDir: repo.Root, TestPrefix: testPrefix(t.file)}
+	a.Coverage, a.Mutation = nil, nil
+	if isGoSource(t.file) {
+		a.Workspace.TestDir = path.Dir(filepath.ToSlash(t.file))
+		if a.CoverageTarget > 0 {
+			analyzer, err := coverage.NewAnalyzer(repo, t.file, t.functions)
+			if err != nil {
+				return nil, err
+			}
+			a.Coverage = analyzer
+		}
+		if a.MutationTarget > 0 {
+			tester := mutation.New(repo.Root, t.file, t.functions)
+			if opts.maxMutants > 0 {
+				tester.Max = opts.maxMutants
+			}
+			a.Mutation = tester
+		}
+	}
+	return a.RunWithPlan(ctx, plan, t.fileContent)
+}
+
+func writePlan(path string, plan *agent.TestPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
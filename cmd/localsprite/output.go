@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/writeback"
+)
+
+// outputOptions select how passing tests are delivered to the developer.
+type outputOptions struct {
+	write     bool
+	overwrite bool
+	diff      string
+	patch     string
+	branch    string
+}
+
+// deliver writes the passing tests of the targets into the repository, as a
+// diff or patch file, or as a commit on a new branch, as requested.
+func deliver(ctx context.Context, repo string, files []agent.Artifact, targets []string, o outputOptions) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	if o.write {
+		if err := writeback.Write(repo, files, o.overwrite); err != nil {
+			return fmt.Errorf("failed to write tests: %w", err)
+		}
+		for _, f := range files {
+			fmt.Printf("[LocalSprite] Wrote %s\n", f.Path)
+		}
+	}
+
+	if o.diff == "" && o.patch == "" && o.branch == "" {
+		return nil
+	}
+	commit, err := writeback.Commit(ctx, repo, files, commitMessage(files, targets))
+	if err != nil {
+		return fmt.Errorf("failed to commit tests: %w", err)
+	}
+
+	if o.diff != "" {
+		diff, err := writeback.Diff(ctx, repo, commit)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(o.diff, []byte(diff), 0o644); err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+		fmt.Printf("[LocalSprite] Wrote diff to %s\n", o.diff)
+	}
+	if o.patch != "" {
+		patch, err := writeback.FormatPatch(ctx, repo, commit)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(o.patch, []byte(patch), 0o644); err != nil {
+			return fmt.Errorf("failed to write patch: %w", err)
+		}
+		fmt.Printf("[LocalSprite] Wrote patch to %s\n", o.patch)
+	}
+	if o.branch != "" {
+		if err := writeback.Branch(ctx, repo, o.branch, commit); err != nil {
+			return err
+		}
+		fmt.Printf("[LocalSprite] Committed %d file(s) to branch %s\n", len(files), o.branch)
+	}
+	return nil
+}
+
+// mergeFiles appends the files of another target. A file generated again
+// with the same content, e.g. shared testdata, is kept once; a different
+// file at the same path is an error, since either target's tests would
+// lose a file they passed with.
+func mergeFiles(files, more []agent.Artifact) ([]agent.Artifact, error) {
+	prev := map[string]string{}
+	for _, f := range files {
+		prev[f.Path] = f.Content
+	}
+	merged := files
+	for _, f := range more {
+		content, ok := prev[f.Path]
+		if !ok {
+			merged = append(merged, f)
+			continue
+		}
+		if content != f.Content {
+			return nil, fmt.Errorf("%s was already generated for an earlier target", f.Path)
+		}
+	}
+	return merged, nil
+}
+
+func commitMessage(files []agent.Artifact, targets []string) string {
+	var named []string
+	for _, t := range targets {
+		if t != "" {
+			named = append(named, t)
+		}
+	}
+
+	var b strings.Builder
+	if len(named) > 0 {
+		fmt.Fprintf(&b, "Add generated tests for %s\n\n", strings.Join(named, ", "))
+	} else {
+		b.WriteString("Add generated tests\n\n")
+	}
+	b.WriteString("Generated by LocalSprite. Files:\n\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "  %s\n", f.Path)
+	}
+	return b.String()
+}
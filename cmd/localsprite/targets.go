@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/gitdiff"
+	"localsprite/internal/goanalysis"
+	"localsprite/internal/repocontext"
+)
+
+// target is a file to generate tests for.
+type target struct {
+	file string
+
+	// fileContent is sent to the coder: the focused functions for Go files,
+	// the whole file otherwise
+	fileContent string
+
+	// focus renders the Go functions under test, if any
+	focus string
+
+	// functions names the Go functions under test; none means the whole file
+	functions []string
+
+	// changes describes the change under review in diff mode
+	changes string
+
+	// plan, if set, is run instead of planning the target, e.g. its part of
+	// a stored plan
+	plan *agent.TestPlan
+}
+
+// testPrefix returns the Workspace.TestPrefix of a target, which keeps the
+// unnamed test files of targets in one package or directory apart, e.g.
+// cart_generated_test.go for cart.go. Go tests are placed in the package of
+// the target, other tests at the root, so those are named after the whole
+// path.
+func testPrefix(file string) string {
+	if file == "" {
+		return ""
+	}
+	name := strings.TrimSuffix(file, path.Ext(file))
+	if isGoSource(file) {
+		return path.Base(name)
+	}
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+// sourceExts are the non-Go files that diff mode generates tests for.
+var sourceExts = map[string]bool{
+	".js": true, ".jsx": true, ".mjs": true, ".cjs": true,
+	".ts": true, ".tsx": true, ".vue": true, ".svelte": true,
+}
+
+// fileTarget builds the target for --target. For Go files the planner and
+// coder get the selected functions with their types, callers and tests
+// instead of the whole file; by default, the file's untested exported
+// functions.
+func fileTarget(repo *repocontext.Repo, file, functions string) (target, error) {
+	t := target{file: file}
+	if file == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(repo.Root, file))
+	if err != nil {
+		return t, fmt.Errorf("failed to read target file: %w", err)
+	}
+	t.fileContent = string(data)
+	if !isGoSource(file) {
+		return t, nil
+	}
+
+	pkg, err := loadPackage(repo, file)
+	if err != nil {
+		return t, err
+	}
+	var names []string
+	for _, name := range strings.Split(functions, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		names = pkg.Untested(path.Base(filepath.ToSlash(file)))
+	}
+	t.functions = names
+	if t.focus, err = focus(pkg, file, names); err != nil {
+		return t, err
+	}
+	if t.focus != "" {
+		t.fileContent = t.focus
+	}
+	return t, nil
+}
+
+// changedTargets builds one target per source file changed since the merge
+// base of base and HEAD. Go files are focused on the functions whose lines
+// changed; files whose changes touch no function are skipped.
+func changedTargets(ctx context.Context, repo *repocontext.Repo, base string) ([]target, error) {
+	changes, err := gitdiff.Changes(ctx, repo.Root, base)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, f := range repo.Files {
+		known[f] = true
+	}
+	pkgs := map[string]*goanalysis.Package{}
+
+	var (
+		targets []target
+		summary strings.Builder
+	)
+	fmt.Fprintf(&summary, "## Change under review (since %s)\n", base)
+	for _, c := range changes {
+		fmt.Fprintf(&summary, "- %s (%s)", c.Path, c.Status)
+		if c.Status == gitdiff.Deleted || !known[c.Path] || repocontext.IsTestFile(c.Path) ||
+			!(isGoSource(c.Path) || sourceExts[path.Ext(c.Path)]) {
+			summary.WriteString("\n")
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(repo.Root, filepath.FromSlash(c.Path)))
+		if err != nil {
+			return nil, err
+		}
+		t := target{file: c.Path, fileContent: string(data)}
+
+		if isGoSource(c.Path) {
+			dir := path.Dir(c.Path)
+			pkg, ok := pkgs[dir]
+			if !ok {
+				if pkg, err = loadPackage(repo, c.Path); err != nil {
+					return nil, err
+				}
+				pkgs[dir] = pkg
+			}
+			names := pkg.FunctionsIn(path.Base(c.Path), c.Overlaps)
+			if len(names) == 0 {
+				summary.WriteString(": no functions changed\n")
+				continue
+			}
+			fmt.Fprintf(&summary, ": %s", strings.Join(names, ", "))
+			t.functions = names
+			if t.focus, err = focus(pkg, c.Path, names); err != nil {
+				return nil, err
+			}
+			t.fileContent = t.focus
+		}
+		summary.WriteString("\n")
+		targets = append(targets, t)
+	}
+
+	for i := range targets {
+		targets[i].changes = summary.String()
+	}
+	return targets, nil
+}
+
+// planTargets builds one target per target file of a stored plan, so that
+// each part of the plan gets its own coder call. The targets are focused as
+// with --target.
+func planTargets(repo *repocontext.Repo, plan *agent.TestPlan) ([]target, error) {
+	var targets []target
+	for _, sub := range plan.SplitByTarget() {
+		file := sub.Scenarios[0].TargetFile
+		if file != "" {
+			if err := agent.ValidatePath(file); err != nil {
+				return nil, fmt.Errorf("plan target %w", err)
+			}
+		}
+		t, err := fileTarget(repo, file, "")
+		if err != nil {
+			return nil, fmt.Errorf("plan target %s: %w", file, err)
+		}
+		t.plan = sub
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func loadPackage(repo *repocontext.Repo, file string) (*goanalysis.Package, error) {
+	pkg, err := goanalysis.Load(repo, path.Dir(filepath.ToSlash(file)))
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range pkg.TypeErrors {
+		fmt.Printf("[LocalSprite] Warning: %v\n", err)
+	}
+	return pkg, nil
+}
+
+// focus renders the named functions of file's package, or "" if there are
+// none.
+func focus(pkg *goanalysis.Package, file string, names []string) (string, error) {
+	if len(names) == 0 {
+		return "", nil
+	}
+	targets := make([]*goanalysis.Target, 0, len(names))
+	for _, name := range names {
+		t, err := pkg.Target(name)
+		if err != nil {
+			return "", err
+		}
+		targets = append(targets, t)
+	}
+	fmt.Printf("[LocalSprite] %s: focusing on %s\n", file, strings.Join(names, ", "))
+	return pkg.Render(targets), nil
+}
+
+func isGoSource(file string) bool {
+	return strings.HasSuffix(file, ".go") && !strings.HasSuffix(file, "_test.go")
+}
@@ -0,0 +1,323 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakePlanner struct{}
+
+func (fakePlanner) Plan(context.Context, string) (*TestPlan, error) {
+	return &TestPlan{Summary: "plan", Scenarios: []Scenario{{Name: "login"}}}, nil
+}
+
+type fakeCoder struct {
+	generated []*TestPlan
+}
+
+func (c *fakeCoder) GenerateCode(ctx context.Context, plan *TestPlan, fileContent string) ([]Artifact, error) {
+	c.generated = append(c.generated, plan)
+	return []Artifact{{Content: "code"}}, nil
+}
+
+type repairingCoder struct {
+	fakeCoder
+	feedback []string
+}
+
+func (c *repairingCoder) RepairCode(ctx context.Context, plan *TestPlan, fileContent string, previous []Artifact, feedback string) ([]Artifact, error) {
+	c.feedback = append(c.feedback, feedback)
+	return []Artifact{{Path: "pkg/a_test.go", Content: "fixed"}, {Path: "pkg/testdata/in.json", Content: "{}"}}, nil
+}
+
+type scriptedExecutor struct {
+	results    []*ExecutionResult
+	runs       [][]Artifact
+	workspaces []Workspace
+}
+
+func (e *scriptedExecutor) Execute(ctx context.Context, ws Workspace, files []Artifact) (*ExecutionResult, error) {
+	e.runs = append(e.runs, files)
+	e.workspaces = append(e.workspaces, ws)
+	res := e.results[0]
+	if len(e.results) > 1 {
+		e.results = e.results[1:]
+	}
+	return res, nil
+}
+
+func TestRun_RepairsUntilPass(t *testing.T) {
+	c := &repairingCoder{}
+	e := &scriptedExecutor{results: []*ExecutionResult{
+		{ExitCode: 1, Stdout: "--- FAIL: TestX\nFAIL", Tests: []TestOutcome{{Name: "TestX", Status: TestFailed}}},
+		{ExitCode: 0, Stdout: "ok  \tpkg\t0.01s"},
+	}}
+	a := NewAgent(fakePlanner{}, c, e)
+	a.MaxIterations = 3
+	a.Workspace = Workspace{SourceDir: "/src", TestDir: "pkg"}
+
+	result, err := a.Run(context.Background(), "ctx", "file")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Files) != 2 || result.Files[0].Path != "pkg/a_test.go" {
+		t.Errorf("expected the passing files in the result, got %+v", result.Files)
+	}
+	if len(e.runs) != 2 || len(e.runs[1]) != 2 || e.runs[1][0].Content != "fixed" {
+		t.Errorf("expected repaired files on second run, got %v", e.runs)
+	}
+	for _, ws := range e.workspaces {
+		if ws != a.Workspace {
+			t.Errorf("expected workspace %+v on every run, got %+v", a.Workspace, ws)
+		}
+	}
+	if len(c.feedback) != 1 || !strings.Contains(c.feedback[0], "--- FAIL: TestX") {
+		t.Errorf("expected executor output fed back to coder, got %v", c.feedback)
+	}
+	if !strings.Contains(c.feedback[0], "FAILED: TestX") {
+		t.Errorf("expected failed test summary in feedback, got %v", c.feedback)
+	}
+}
+
+func TestRun_StopsAtMaxIterations(t *testing.T) {
+	c := &fakeCoder{}
+	e := &scriptedExecutor{results: []*ExecutionResult{{ExitCode: 1, Stdout: "FAIL\tpkg [build failed]"}}}
+	a := NewAgent(fakePlanner{}, c, e)
+	a.MaxIterations = 2
+
+	result, err := a.Run(context.Background(), "ctx", "file")
+	if err == nil {
+		t.Fatal("expected error when tests never pass")
+	}
+	if result == nil || result.Passed() || len(result.Files) != 1 {
+		t.Errorf("expected the last failing result, got %+v", result)
+	}
+	if len(e.runs) != 2 {
+		t.Errorf("expected 2 executions, got %d", len(e.runs))
+	}
+	if len(c.generated) != 2 || !strings.Contains(c.generated[1].Notes, "[build failed]") {
+		t.Errorf("expected fallback regeneration with feedback in plan notes, got %v", c.generated)
+	}
+	if c.generated[0].Notes != "" {
+		t.Errorf("expected original plan to be left untouched, got notes %q", c.generated[0].Notes)
+	}
+}
+
+// scriptedValidator reports a problem in the first files it sees and
+// formats later ones.
+type scriptedValidator struct {
+	calls int
+}
+
+func (v *scriptedValidator) Validate(ctx context.Context, ws Workspace, files []Artifact) ([]Artifact, []Diagnostic, error) {
+	v.calls++
+	if v.calls == 1 {
+		return files, []Diagnostic{{File: "a_test.go", Line: 3, Source: "syntax", Message: "expected ';'"}}, nil
+	}
+	fixed := append([]Artifact(nil), files...)
+	fixed[0].Content += "// formatted"
+	return fixed, nil, nil
+}
+
+func TestRun_ValidationSkipsExecution(t *testing.T) {
+	c := &repairingCoder{}
+	v := &scriptedValidator{}
+	e := &scriptedExecutor{results: []*ExecutionResult{{ExitCode: 0}}}
+	a := NewAgent(fakePlanner{}, c, e)
+	a.Validator = v
+	a.MaxIterations = 2
+
+	result, err := a.Run(context.Background(), "ctx", "file")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(e.runs) != 1 {
+		t.Fatalf("expected invalid code not to be executed, got %d runs", len(e.runs))
+	}
+	if e.runs[0][0].Content != "fixed// formatted" {
+		t.Errorf("expected the validator's fixes to be executed, got %q", e.runs[0][0].Content)
+	}
+	if len(c.feedback) != 1 || !strings.Contains(c.feedback[0], "a_test.go:3: expected ';' (syntax)") {
+		t.Errorf("expected diagnostics fed back to coder, got %v", c.feedback)
+	}
+	if !result.Passed() {
+		t.Errorf("expected final result to pass, got %s", result.Summary())
+	}
+}
+
+func TestExecutionResult_Diagnostics(t *testing.T) {
+	r := &ExecutionResult{Diagnostics: []Diagnostic{{File: "a_test.go", Line: 2, Column: 5, Source: "vet", Message: "undefined: Foo"}}}
+	if r.Passed() {
+		t.Error("expected a result with diagnostics not to pass")
+	}
+	if got := r.Feedback(); !strings.Contains(got, "not executed") || !strings.Contains(got, "a_test.go:2:5: undefined: Foo (vet)") {
+		t.Errorf("unexpected feedback %q", got)
+	}
+}
+
+type errExecutor struct{}
+
+func (errExecutor) Execute(context.Context, Workspace, []Artifact) (*ExecutionResult, error) {
+	return nil, errors.New("docker down")
+}
+
+func TestRun_ExecutorError(t *testing.T) {
+	a := NewAgent(fakePlanner{}, &fakeCoder{}, errExecutor{})
+	if _, err := a.Run(context.Background(), "ctx", "file"); err == nil || !strings.Contains(err.Error(), "docker down") {
+		t.Errorf("expected executor error, got %v", err)
+	}
+}
+
+type escapingCoder struct{}
+
+func (escapingCoder) GenerateCode(context.Context, *TestPlan, string) ([]Artifact, error) {
+	return []Artifact{{Path: "../../etc/passwd", Content: "x"}}, nil
+}
+
+func TestRun_RejectsEscapingArtifact(t *testing.T) {
+	e := &scriptedExecutor{results: []*ExecutionResult{{}}}
+	_, err := NewAgent(fakePlanner{}, escapingCoder{}, e).Run(context.Background(), "ctx", "file")
+	if err == nil || !strings.Contains(err.Error(), "outside the workspace") {
+		t.Errorf("expected path traversal to be rejected, got %v", err)
+	}
+	if len(e.runs) != 0 {
+		t.Errorf("expected nothing to be executed, got %v", e.runs)
+	}
+}
+
+func TestExecutionResult_Passed(t *testing.T) {
+	if !(&ExecutionResult{ExitCode: 0}).Passed() {
+		t.Error("expected exit code 0 to pass")
+	}
+	if (&ExecutionResult{ExitCode: 1}).Passed() {
+		t.Error("expected exit code 1 to fail")
+	}
+	if (&ExecutionResult{ExitCode: 0, TimedOut: true}).Passed() {
+		t.Error("expected timed out run to fail")
+	}
+}
+
+type blockingPlanner struct{}
+
+func (blockingPlanner) Plan(ctx context.Context, repoContext string) (*TestPlan, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRun_PlanTimeout(t *testing.T) {
+	a := NewAgent(blockingPlanner{}, &fakeCoder{}, errExecutor{})
+	a.PlanTimeout = 10 * time.Millisecond
+
+	_, err := a.Run(context.Background(), "ctx", "file")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected plan deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewAgent(blockingPlanner{}, &fakeCoder{}, errExecutor{}).Run(ctx, "ctx", "file")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+}
+
+type emptyPlanner struct{}
+
+func (emptyPlanner) Plan(context.Context, string) (*TestPlan, error) { return &TestPlan{}, nil }
+
+func TestRun_InvalidPlan(t *testing.T) {
+	c := &fakeCoder{}
+	_, err := NewAgent(emptyPlanner{}, c, errExecutor{}).Run(context.Background(), "ctx", "file")
+	if err == nil || !strings.Contains(err.Error(), "no scenarios") {
+		t.Errorf("expected invalid plan error, got %v", err)
+	}
+	if len(c.generated) != 0 {
+		t.Errorf("expected coder not to be called, got %d calls", len(c.generated))
+	}
+}
+
+type fakeQuarantiner struct {
+	tests []TestOutcome
+}
+
+func (q *fakeQuarantiner) Quarantine(files []Artifact, tests []TestOutcome) ([]Artifact, error) {
+	q.tests = tests
+	return []Artifact{{Path: files[0].Path, Content: "skipped"}}, nil
+}
+
+func TestRun_QuarantinesFlakyTests(t *testing.T) {
+	flaky := &ExecutionResult{ExitCode: 1, Runs: 3, Tests: []TestOutcome{
+		{Name: "TestStable", Status: TestPassed, Runs: 3},
+		{Name: "TestRace", Status: TestFlaky, Runs: 3, Failures: 1},
+	}}
+	e := &scriptedExecutor{results: []*ExecutionResult{flaky, {ExitCode: 0, Runs: 3}}}
+	q := &fakeQuarantiner{}
+	a := NewAgent(fakePlanner{}, &fakeCoder{}, e)
+	a.Quarantiner = q
+
+	result, err := a.Run(context.Background(), "ctx", "file")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(q.tests) != 1 || q.tests[0].Name != "TestRace" {
+		t.Errorf("expected only the flaky test to be quarantined, got %+v", q.tests)
+	}
+	if len(e.runs) != 2 || e.runs[1][0].Content != "skipped" {
+		t.Errorf("expected the quarantined files to be run again, got %v", e.runs)
+	}
+	if result.Files[0].Content != "skipped" || len(result.Quarantined) != 1 {
+		t.Errorf("expected the quarantined files in the result, got %+v", result)
+	}
+	if !strings.Contains(result.Summary(), "QUARANTINED: TestRace (flaky, failed 1 of 3 runs)") {
+		t.Errorf("expected quarantined tests in the summary, got %q", result.Summary())
+	}
+}
+
+func TestRun_RepairsStableFailuresBeforeQuarantine(t *testing.T) {
+	failing := &ExecutionResult{ExitCode: 1, Tests: []TestOutcome{
+		{Name: "TestBroken", Status: TestFailed},
+		{Name: "TestRace", Status: TestFlaky},
+	}}
+	e := &scriptedExecutor{results: []*ExecutionResult{failing}}
+	q := &fakeQuarantiner{}
+	a := NewAgent(fakePlanner{}, &fakeCoder{}, e)
+	a.Quarantiner = q
+
+	if _, err := a.Run(context.Background(), "ctx", "file"); err == nil {
+		t.Error("expected the stable failure to fail the run")
+	}
+	if q.tests != nil {
+		t.Errorf("expected no quarantine with stable failures, got %+v", q.tests)
+	}
+}
+
+func TestClassifyRuns(t *testing.T) {
+	tests := ClassifyRuns([]TestOutcome{
+		{Name: "TestA", Status: TestPassed},
+		{Name: "TestB", Status: TestFailed, Output: "first"},
+		{Name: "TestC", Status: TestPassed},
+		{Name: "TestA", Status: TestPassed},
+		{Name: "TestB", Status: TestFailed, Output: "second"},
+		{Name: "TestC", Status: TestFailed, Output: "boom"},
+		{Name: "TestC", Status: TestSkipped},
+	})
+	want := []TestOutcome{
+		{Name: "TestA", Status: TestPassed, Runs: 2},
+		{Name: "TestB", Status: TestFailed, Output: "first", Runs: 2, Failures: 2},
+		{Name: "TestC", Status: TestFlaky, Output: "boom", Runs: 3, Failures: 1},
+	}
+	if len(tests) != len(want) {
+		t.Fatalf("expected %d tests, got %+v", len(want), tests)
+	}
+	for i := range want {
+		if tests[i] != want[i] {
+			t.Errorf("test %d: expected %+v, got %+v", i, want[i], tests[i])
+		}
+	}
+}
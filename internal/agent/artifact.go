@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Artifact is a file produced by a Coder: a test file, fixture, page object
+// or testdata input.
+type Artifact struct {
+	// Path is the slash-separated path relative to the repository root, or
+	// to the container's working directory when no source is mounted. Empty
+	// means the executor's default test file in Workspace.TestDir.
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content"`
+}
+
+// ValidateArtifacts rejects absolute paths, paths that escape the root or
+// point into .git, and duplicate paths.
+func ValidateArtifacts(files []Artifact) error {
+	if len(files) == 0 {
+		return errors.New("no files generated")
+	}
+
+	seen := map[string]bool{}
+	for _, f := range files {
+		p := f.Path
+		if p != "" {
+			if err := ValidatePath(p); err != nil {
+				return fmt.Errorf("generated file %w", err)
+			}
+			p = path.Clean(p)
+		}
+		if seen[p] {
+			if p == "" {
+				return errors.New("more than one unnamed file generated")
+			}
+			return fmt.Errorf("generated file %q appears more than once", f.Path)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// ValidatePath rejects a slash-separated path that is absolute, escapes the
+// root or points into .git.
+func ValidatePath(p string) error {
+	if strings.Contains(p, `\`) || !filepath.IsLocal(filepath.FromSlash(p)) {
+		return fmt.Errorf("%q is outside the workspace", p)
+	}
+	if p = path.Clean(p); p == ".git" || strings.HasPrefix(p, ".git/") {
+		return fmt.Errorf("%q is inside .git", p)
+	}
+	return nil
+}
+
+// FormatArtifacts renders files in the format coders are asked to answer in,
+// e.g. to show a model its previous attempt.
+func FormatArtifacts(files []Artifact) string {
+	var b strings.Builder
+	for i, f := range files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if f.Path != "" {
+			fmt.Fprintf(&b, "File: %s\n", f.Path)
+		}
+		fmt.Fprintf(&b, "```\n%s", f.Content)
+		if !strings.HasSuffix(f.Content, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("```\n")
+	}
+	return b.String()
+}
+
+var codeFence = regexp.MustCompile("(?s)```([^`\\n]*)\\n(.*?)```")
+
+// ParseArtifacts extracts the files from a model response. Fenced code blocks
+// are named by a "File: path" line (or a Markdown heading holding the path)
+// just before the block, or by a path after the language in the fence line.
+// If no block is named, the largest block, or the whole response when it
+// contains no fences, becomes a single unnamed artifact. It returns nil when
+// there is no code at all.
+func ParseArtifacts(response string) []Artifact {
+	var (
+		named   []Artifact
+		largest string
+		prev    int
+	)
+	for _, m := range codeFence.FindAllStringSubmatchIndex(response, -1) {
+		info := response[m[2]:m[3]]
+		content := response[m[4]:m[5]]
+		before := response[prev:m[0]]
+		prev = m[1]
+
+		if len(content) > len(largest) {
+			largest = content
+		}
+		name := fencePath(info)
+		if name == "" {
+			name = headingPath(before)
+		}
+		if name == "" || strings.TrimSpace(content) == "" {
+			continue
+		}
+		named = append(named, Artifact{Path: name, Content: strings.TrimSpace(content) + "\n"})
+	}
+	if len(named) > 0 {
+		return named
+	}
+
+	if largest == "" {
+		largest = response
+	}
+	if largest = strings.TrimSpace(largest); largest == "" {
+		return nil
+	}
+	return []Artifact{{Content: largest + "\n"}}
+}
+
+// fencePath reads "go path/to/file_test.go" or `ts title="pages/login.ts"`
+// from a fence's info string.
+func fencePath(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) < 2 {
+		return ""
+	}
+	last := fields[len(fields)-1]
+	for _, prefix := range []string{"title=", "file=", "path="} {
+		last = strings.TrimPrefix(last, prefix)
+	}
+	return cleanPath(strings.Trim(last, `"'`))
+}
+
+// headingPath reads the path from the last non-empty line of text, such as
+// "File: a/b_test.go", "### `a/b_test.go`" or "**a/b_test.go**:".
+func headingPath(text string) string {
+	text = strings.TrimRight(text, " \t\r\n")
+	line := text[strings.LastIndexByte(text, '\n')+1:]
+	line = strings.TrimLeft(strings.TrimSpace(line), "#>-* ")
+	for _, prefix := range []string{"File:", "file:", "Path:", "path:", "Filename:", "filename:"} {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	line = strings.TrimSuffix(strings.TrimSpace(line), ":")
+	return cleanPath(strings.Trim(line, "`*\"' "))
+}
+
+// cleanPath returns s if it looks like a file path: no spaces, and a
+// directory or an extension.
+func cleanPath(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t") {
+		return ""
+	}
+	if ext := path.Ext(s); !strings.Contains(s, "/") && len(ext) < 2 {
+		return ""
+	}
+	return strings.TrimPrefix(s, "./")
+}
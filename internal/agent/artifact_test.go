@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArtifacts_Unnamed(t *testing.T) {
+	cases := map[string]string{
+		"package a\n":                               "package a\n",
+		"```\npackage a\n```":                       "package a\n",
+		"```go\nx\n```\n```go\nlonger\n```":         "longer\n",
+		"Here it is.\n```go\npackage a\n```\nDone.": "package a\n",
+	}
+	for in, want := range cases {
+		got := ParseArtifacts(in)
+		if len(got) != 1 || got[0].Path != "" || got[0].Content != want {
+			t.Errorf("ParseArtifacts(%q) = %+v, want one unnamed file %q", in, got, want)
+		}
+	}
+	if got := ParseArtifacts("   "); got != nil {
+		t.Errorf("expected no artifacts for an empty response, got %+v", got)
+	}
+}
+
+func TestParseArtifacts_Named(t *testing.T) {
+	response := "Two files:\n\n" +
+		"File: cart/cart_test.go\n```go\npackage cart\n```\n\n" +
+		"### `cart/testdata/items.json`\n```json\n[]\n```\n\n" +
+		"```ts pages/login.ts\nexport class LoginPage {}\n```\n\n" +
+		"Run with:\n```sh\ngo test ./...\n```\n"
+
+	want := []Artifact{
+		{Path: "cart/cart_test.go", Content: "package cart\n"},
+		{Path: "cart/testdata/items.json", Content: "[]\n"},
+		{Path: "pages/login.ts", Content: "export class LoginPage {}\n"},
+	}
+	if got := ParseArtifacts(response); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseArtifacts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseArtifacts_RoundTrip(t *testing.T) {
+	files := []Artifact{
+		{Path: "a/a_test.go", Content: "package a\n"},
+		{Path: "a/testdata/in.txt", Content: "hello\n"},
+	}
+	if got := ParseArtifacts(FormatArtifacts(files)); !reflect.DeepEqual(got, files) {
+		t.Errorf("round trip = %+v, want %+v", got, files)
+	}
+}
+
+func TestValidateArtifacts(t *testing.T) {
+	cases := []struct {
+		files []Artifact
+		err   string
+	}{
+		{[]Artifact{{Content: "x"}}, ""},
+		{[]Artifact{{Path: "a/a_test.go"}, {Path: "a/testdata/in.json"}}, ""},
+		{nil, "no files"},
+		{[]Artifact{{Path: "../x_test.go"}}, "outside the workspace"},
+		{[]Artifact{{Path: "a/../../x_test.go"}}, "outside the workspace"},
+		{[]Artifact{{Path: "/etc/passwd"}}, "outside the workspace"},
+		{[]Artifact{{Path: `a\..\..\x`}}, "outside the workspace"},
+		{[]Artifact{{Path: ".git/hooks/pre-commit"}}, "inside .git"},
+		{[]Artifact{{Path: "a/x.go"}, {Path: "a/./x.go"}}, "more than once"},
+		{[]Artifact{{Content: "x"}, {Content: "y"}}, "more than one unnamed"},
+	}
+	for _, c := range cases {
+		err := ValidateArtifacts(c.files)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("ValidateArtifacts(%+v) failed: %v", c.files, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("ValidateArtifacts(%+v) = %v, want error containing %q", c.files, err, c.err)
+		}
+	}
+}
+
+func TestWorkspace_TestFile(t *testing.T) {
+	cases := []struct {
+		ws   Workspace
+		want string
+	}{
+		{Workspace{}, "generated_test.go"},
+		{Workspace{TestDir: "cart"}, "generated_test.go"},
+		{Workspace{SourceDir: "/src", TestDir: "cart"}, "cart/generated_test.go"},
+		{Workspace{SourceDir: "/src", TestDir: "cart", TestPrefix: "order"}, "cart/order_generated_test.go"},
+	}
+	for _, c := range cases {
+		if got := c.ws.TestFile("generated_test.go"); got != c.want {
+			t.Errorf("%+v.TestFile() = %s, want %s", c.ws, got, c.want)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	for _, p := range []string{"cart/cart.go", "./cart/cart.go"} {
+		if err := ValidatePath(p); err != nil {
+			t.Errorf("ValidatePath(%q) failed: %v", p, err)
+		}
+	}
+	for _, p := range []string{"/etc/passwd", "../x.go", "cart/../../x.go", `a\..\..\x`, ".git/config"} {
+		if err := ValidatePath(p); err == nil {
+			t.Errorf("expected ValidatePath(%q) to fail", p)
+		}
+	}
+}
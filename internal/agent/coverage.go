@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CoverageAnalyzer maps the coverage profile of a run onto the functions
+// under test.
+type CoverageAnalyzer interface {
+	Analyze(blocks []CoverBlock) (*CoverageStatus, error)
+}
+
+// CoverageStatus is the coverage of the functions under test.
+type CoverageStatus struct {
+	Covered    int
+	Statements int
+
+	// Gaps are the ranges of statements that no test ran
+	Gaps []CoverageGap
+}
+
+// CoverageGap is a range of uncovered lines in a function under test.
+type CoverageGap struct {
+	// File is the slash-separated path relative to the repository root
+	File      string
+	Function  string
+	StartLine int
+	EndLine   int
+
+	// Source holds the uncovered lines
+	Source string
+}
+
+// Percent returns the share of the statements that ran, or 0 if none were
+// measured.
+func (s *CoverageStatus) Percent() float64 {
+	if s.Statements == 0 {
+		return 0
+	}
+	return 100 * float64(s.Covered) / float64(s.Statements)
+}
+
+// improveCoverage extends passing tests with scenarios aimed at the lines
+// they leave uncovered, until the coverage target or the round budget is
+// reached. Each round that passes and covers more statements replaces
+// result; a round that fails or gains nothing ends the loop, keeping the
+// tests that passed before it.
+func (a *Agent) improveCoverage(ctx context.Context, plan *TestPlan, fileContent string, result *ExecutionResult) (*ExecutionResult, error) {
+	if a.Coverage == nil || a.CoverageTarget <= 0 {
+		return result, nil
+	}
+	if result.Coverage == nil {
+		fmt.Printf("[Agent] No coverage measured, skipping coverage-guided planning\n")
+		return result, nil
+	}
+	status, err := a.Coverage.Analyze(result.Coverage)
+	if err != nil {
+		fmt.Printf("[Agent] Failed to analyze coverage: %v\n", err)
+		return result, nil
+	}
+
+	for round := 1; ; round++ {
+		fmt.Printf("[Agent] Coverage of the functions under test: %.1f%% (%d/%d statements, target %.1f%%)\n",
+			status.Percent(), status.Covered, status.Statements, a.CoverageTarget)
+		switch {
+		case status.Percent() >= a.CoverageTarget, len(status.Gaps) == 0:
+			return result, nil
+		case round > a.CoverageRounds:
+			fmt.Printf("[Agent] Coverage target not reached after %d round(s)\n", a.CoverageRounds)
+			return result, nil
+		}
+
+		fmt.Printf("[Agent] Coverage round %d/%d: planning tests for %d uncovered range(s)...\n", round, a.CoverageRounds, len(status.Gaps))
+		nextPlan, next, nextStatus, err := a.coverageRound(ctx, plan, fileContent, result, status)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, err
+			}
+			fmt.Printf("[Agent] Coverage round %d failed, keeping the passing tests: %v\n", round, err)
+			return result, nil
+		}
+		if nextStatus.Covered <= status.Covered {
+			fmt.Printf("[Agent] Coverage round %d covered no new statements, keeping the previous tests\n", round)
+			return result, nil
+		}
+		plan, result, status = nextPlan, next, nextStatus
+	}
+}
+
+// coverageRound asks the planner for scenarios aimed at the gaps of status,
+// has the coder extend the passing tests with them, and runs the result
+// through the repair loop. It returns the combined plan and the passing
+// run with its coverage.
+func (a *Agent) coverageRound(ctx context.Context, plan *TestPlan, fileContent string, result *ExecutionResult, status *CoverageStatus) (*TestPlan, *ExecutionResult, *CoverageStatus, error) {
+	gapPlan, err := a.plan(ctx, CoverageContext(plan, fileContent, status))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("planning failed: %w", err)
+	}
+
+	combined, added := mergeScenarios(plan, gapPlan.Scenarios)
+	if len(added) == 0 {
+		return nil, nil, nil, errors.New("planner repeated the existing scenarios")
+	}
+
+	files, err := a.generate(ctx, ExtendPlan(combined, added, result.Files), fileContent)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("code generation failed: %w", err)
+	}
+	next, err := a.attempt(ctx, combined, fileContent, files)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if next.Coverage == nil {
+		return nil, nil, nil, errors.New("no coverage measured")
+	}
+	nextStatus, err := a.Coverage.Analyze(next.Coverage)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to analyze coverage: %w", err)
+	}
+	return combined, next, nextStatus, nil
+}
+
+// mergeScenarios returns a copy of plan with the scenarios whose names it
+// does not have yet appended, and the names of those scenarios.
+func mergeScenarios(plan *TestPlan, scenarios []Scenario) (*TestPlan, []string) {
+	merged := *plan
+	merged.Scenarios = append([]Scenario(nil), plan.Scenarios...)
+	seen := map[string]bool{}
+	for _, s := range plan.Scenarios {
+		seen[s.Name] = true
+	}
+
+	var added []string
+	for _, s := range scenarios {
+		if seen[s.Name] {
+			continue
+		}
+		seen[s.Name] = true
+		merged.Scenarios = append(merged.Scenarios, s)
+		added = append(added, s.Name)
+	}
+	return &merged, added
+}
+
+// CoverageContext renders the input of a follow-up planning call: the code
+// under test, the scenarios already tested, and the lines they leave
+// uncovered.
+func CoverageContext(plan *TestPlan, fileContent string, status *CoverageStatus) string {
+	var b strings.Builder
+	b.WriteString("## Code under test\n\n")
+	b.WriteString(fileContent)
+	b.WriteString("\n\n## Existing test plan\n\nThese scenarios are already tested and pass:\n")
+	for _, s := range plan.Scenarios {
+		fmt.Fprintf(&b, "- %s: %s\n", s.Name, s.Description)
+	}
+
+	fmt.Fprintf(&b, "\n## Uncovered code\n\nThe existing tests cover %d of %d statements (%.1f%%) of the functions under test. ",
+		status.Covered, status.Statements, status.Percent())
+	b.WriteString("Plan only new scenarios, with new names, whose tests run the lines below. ")
+	b.WriteString("Describe the inputs or state that reach each line.\n")
+	for _, g := range status.Gaps {
+		fmt.Fprintf(&b, "\n### %s:%d-%d", g.File, g.StartLine, g.EndLine)
+		if g.Function != "" {
+			fmt.Fprintf(&b, " (%s)", g.Function)
+		}
+		fence := strings.TrimPrefix(path.Ext(g.File), ".")
+		fmt.Fprintf(&b, "\n\n```%s\n%s\n```\n", fence, strings.TrimRight(g.Source, "\n"))
+	}
+	return b.String()
+}
+
+// ExtendPlan returns a copy of plan whose notes ask the coder to keep the
+// passing tests in previous and add tests for the named scenarios.
+func ExtendPlan(plan *TestPlan, added []string, previous []Artifact) *TestPlan {
+	extended := *plan
+	if extended.Notes != "" {
+		extended.Notes += "\n\n"
+	}
+	extended.Notes += fmt.Sprintf(`The code below already passes and tests the other scenarios. Keep it unchanged and add tests for these new scenarios: %s.
+Return every file again, complete, including the existing tests.
+
+Existing code:
+%s`, strings.Join(added, ", "), FormatArtifacts(previous))
+	return &extended
+}
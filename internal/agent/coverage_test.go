@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type scriptedPlanner struct {
+	plans    []*TestPlan
+	contexts []string
+}
+
+func (p *scriptedPlanner) Plan(ctx context.Context, repoContext string) (*TestPlan, error) {
+	p.contexts = append(p.contexts, repoContext)
+	plan := p.plans[0]
+	if len(p.plans) > 1 {
+		p.plans = p.plans[1:]
+	}
+	return plan, nil
+}
+
+// countAnalyzer reports the count of the first block as the number of
+// statements covered out of ten.
+type countAnalyzer struct{}
+
+func (countAnalyzer) Analyze(blocks []CoverBlock) (*CoverageStatus, error) {
+	status := &CoverageStatus{Covered: blocks[0].Count, Statements: 10}
+	if status.Covered < 10 {
+		status.Gaps = []CoverageGap{{File: "cart/cart.go", Function: "Add", StartLine: 7, EndLine: 9, Source: "\tif a < 0 {\n\t\treturn 0\n\t}"}}
+	}
+	return status, nil
+}
+
+func covered(n int) *ExecutionResult {
+	return &ExecutionResult{Coverage: []CoverBlock{{File: "example.com/shop/cart/cart.go", Count: n}}}
+}
+
+func TestRun_CoverageGuided(t *testing.T) {
+	p := &scriptedPlanner{plans: []*TestPlan{
+		{Summary: "plan", Scenarios: []Scenario{{Name: "adds"}}},
+		{Summary: "more", Scenarios: []Scenario{{Name: "adds"}, {Name: "negative", Description: "a < 0"}}},
+		{Summary: "again", Scenarios: []Scenario{{Name: "negative"}}},
+	}}
+	c := &fakeCoder{}
+	e := &scriptedExecutor{results: []*ExecutionResult{covered(5), covered(8), covered(9)}}
+	a := NewAgent(p, c, e)
+	a.Coverage = countAnalyzer{}
+	a.CoverageTarget = 90
+	a.CoverageRounds = 3
+
+	result, err := a.Run(context.Background(), "ctx", "func Add(a, b int) int")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Coverage[0].Count != 8 {
+		t.Errorf("expected the tests of the first coverage round, got %+v", result.Coverage)
+	}
+	if len(e.runs) != 2 {
+		t.Errorf("expected a repeated plan to end the loop before executing, got %d runs", len(e.runs))
+	}
+
+	if len(p.contexts) != 3 || !strings.Contains(p.contexts[1], "cart/cart.go:7-9 (Add)") || !strings.Contains(p.contexts[1], "return 0") {
+		t.Errorf("expected the uncovered lines in the planner context, got %q", p.contexts)
+	}
+	if !strings.Contains(p.contexts[1], "- adds: ") {
+		t.Errorf("expected the existing scenarios in the planner context, got %q", p.contexts[1])
+	}
+
+	extended := c.generated[1]
+	if len(extended.Scenarios) != 2 || extended.Scenarios[1].Name != "negative" {
+		t.Errorf("expected the new scenario added to the plan, got %+v", extended.Scenarios)
+	}
+	if !strings.Contains(extended.Notes, "add tests for these new scenarios: negative") || !strings.Contains(extended.Notes, "code") {
+		t.Errorf("expected the coder to extend the passing tests, got %q", extended.Notes)
+	}
+}
+
+func TestRun_CoverageTargetReached(t *testing.T) {
+	p := &scriptedPlanner{plans: []*TestPlan{{Summary: "plan", Scenarios: []Scenario{{Name: "adds"}}}}}
+	e := &scriptedExecutor{results: []*ExecutionResult{covered(9)}}
+	a := NewAgent(p, &fakeCoder{}, e)
+	a.Coverage = countAnalyzer{}
+	a.CoverageTarget = 90
+	a.CoverageRounds = 3
+
+	if _, err := a.Run(context.Background(), "ctx", "file"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(p.contexts) != 1 || len(e.runs) != 1 {
+		t.Errorf("expected no coverage rounds once the target is met, got %d plans and %d runs", len(p.contexts), len(e.runs))
+	}
+}
+
+func TestCoverageStatus_Percent(t *testing.T) {
+	if p := (&CoverageStatus{Covered: 3, Statements: 4}).Percent(); p != 75 {
+		t.Errorf("expected 75%%, got %v", p)
+	}
+	if p := (&CoverageStatus{}).Percent(); p != 0 {
+		t.Errorf("expected nothing measured to be 0%%, got %v", p)
+	}
+}
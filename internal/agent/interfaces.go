@@ -1,18 +1,79 @@
 package agent
 
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
 // Planner analyzes the repo context and creates a test plan.
 type Planner interface {
-	Plan(repoContext string) (string, error)
+	Plan(ctx context.Context, repoContext string) (*TestPlan, error)
 }
 
-// Coder generates code based on the plan and target file.
+// Coder generates test files based on the plan and target file.
 type Coder interface {
-	GenerateCode(plan string, fileContent string) (string, error)
+	GenerateCode(ctx context.Context, plan *TestPlan, fileContent string) ([]Artifact, error)
+}
+
+// Repairer is implemented by coders that can fix previously generated code
+// given the executor output it produced. Coders that do not implement it are
+// asked to regenerate with the failure folded into the plan.
+type Repairer interface {
+	RepairCode(ctx context.Context, plan *TestPlan, fileContent string, previous []Artifact, feedback string) ([]Artifact, error)
 }
 
-// Executor runs the generated code and returns logs.
+// Executor writes the generated files into the workspace, runs the tests and
+// returns the outcome of the run. A failing test run is reported through the
+// result, not as an error. Cancelling ctx stops and removes any running
+// container.
 type Executor interface {
-	Execute(code string) (string, error)
+	Execute(ctx context.Context, ws Workspace, files []Artifact) (*ExecutionResult, error)
+}
+
+// Validator checks generated files before they are executed, fixing what
+// it can, such as formatting and imports. The problems it cannot fix are
+// returned as diagnostics; they skip execution and are fed back to the coder.
+// An error means validation itself could not run.
+type Validator interface {
+	Validate(ctx context.Context, ws Workspace, files []Artifact) ([]Artifact, []Diagnostic, error)
+}
+
+// Quarantiner skips flaky tests in generated files, so that the rest of the
+// suite can be delivered. It fails if a test cannot be found.
+type Quarantiner interface {
+	Quarantine(files []Artifact, tests []TestOutcome) ([]Artifact, error)
+}
+
+// Workspace describes the project the generated tests run against.
+type Workspace struct {
+	// SourceDir is the local checkout copied into the container, with the
+	// original files made read-only. Empty runs the tests on their own.
+	SourceDir string
+
+	// TestDir is the slash-separated directory, relative to SourceDir, that
+	// an unnamed generated test file is placed in, i.e. the package under
+	// test.
+	TestDir string
+
+	// TestPrefix, if set, is prepended to the executor's test file name for
+	// an unnamed generated file, so that targets sharing a package get
+	// separate files.
+	TestPrefix string
+}
+
+// TestFile returns the slash-separated path that an unnamed generated file
+// is written to, given the executor's test file name.
+func (ws Workspace) TestFile(name string) string {
+	if ws.TestPrefix != "" {
+		name = ws.TestPrefix + "_" + name
+	}
+	if ws.SourceDir != "" && ws.TestDir != "" {
+		return path.Join(ws.TestDir, name)
+	}
+	return name
 }
 
 // Agent orchestrates the components.
@@ -20,34 +81,272 @@ type Agent struct {
 	Planner  Planner
 	Coder    Coder
 	Executor Executor
+
+	// Validator, if set, checks the generated files before each execution
+	Validator Validator
+
+	// Quarantiner, if set, skips the tests found to be flaky over repeated
+	// runs when they are the only failures, and the suite is run again
+	Quarantiner Quarantiner
+
+	// Workspace is passed to every execution
+	Workspace Workspace
+
+	// MaxIterations bounds the generate/execute/repair loop. Values below 1
+	// are treated as a single attempt.
+	MaxIterations int
+
+	// PlanTimeout and CodeTimeout bound each planner and coder call. Zero
+	// means the call is only bounded by the context passed to Run.
+	PlanTimeout time.Duration
+	CodeTimeout time.Duration
+
+	// Coverage, if set together with a CoverageTarget above zero, measures
+	// the coverage of the functions under test after the tests pass. While
+	// it is below CoverageTarget (a percentage of their statements), the
+	// planner is asked for scenarios aimed at the uncovered lines, for at
+	// most CoverageRounds more rounds.
+	Coverage       CoverageAnalyzer
+	CoverageTarget float64
+	CoverageRounds int
+
+	// Mutation, if set, scores passing tests against mutants of the code
+	// under test. While the score is below MutationTarget (a percentage of
+	// the compiling mutants killed), the surviving mutants are fed back to
+	// the coder, for at most MutationRounds rounds. It needs an executor
+	// that implements CommandExecutor.
+	Mutation       MutationTester
+	MutationTarget float64
+	MutationRounds int
 }
 
 func NewAgent(p Planner, c Coder, e Executor) *Agent {
 	return &Agent{
-		Planner:  p,
-		Coder:    c,
-		Executor: e,
+		Planner:       p,
+		Coder:         c,
+		Executor:      e,
+		MaxIterations: 1,
 	}
 }
 
-func (a *Agent) Run(repoContext, fileContent string) error {
+// Run plans, generates and executes tests, repairing them until they pass
+// or MaxIterations is reached. With a CoverageTarget, passing tests are
+// then extended until they cover enough of the functions under test, and
+// with a MutationTester they are strengthened against mutants. It
+// returns the passing execution, whose Files are the tests that passed, or
+// the last failing attempt together with an error. Cancelling ctx aborts
+// the in-flight stage.
+func (a *Agent) Run(ctx context.Context, repoContext, fileContent string) (*ExecutionResult, error) {
 	// 1. Plan
-	plan, err := a.Planner.Plan(repoContext)
+	plan, err := a.plan(ctx, repoContext)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("planning failed: %w", err)
+	}
+
+	return a.RunWithPlan(ctx, plan, fileContent)
+}
+
+// RunWithPlan generates, executes and repairs tests for an existing plan,
+// e.g. one that was stored and edited after an earlier run. Its results are
+// those of Run.
+func (a *Agent) RunWithPlan(ctx context.Context, plan *TestPlan, fileContent string) (*ExecutionResult, error) {
+	if err := plan.Validate(); err != nil {
+		return nil, err
 	}
 
 	// 2. Code
-	code, err := a.Coder.GenerateCode(plan, fileContent)
+	files, err := a.generate(ctx, plan, fileContent)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("code generation failed: %w", err)
 	}
 
-	// 3. Execute
-	_, err = a.Executor.Execute(code)
+	result, err := a.attempt(ctx, plan, fileContent, files)
 	if err != nil {
-		return err
+		return result, err
+	}
+
+	// 5. Plan more tests for the lines left uncovered
+	if result, err = a.improveCoverage(ctx, plan, fileContent, result); err != nil {
+		return result, err
+	}
+
+	// 6. Strengthen the tests against mutants they do not detect
+	return a.strengthen(ctx, plan, fileContent, result)
+}
+
+// attempt executes files and repairs them until they pass or MaxIterations
+// is reached.
+func (a *Agent) attempt(ctx context.Context, plan *TestPlan, fileContent string, files []Artifact) (*ExecutionResult, error) {
+	maxIterations := a.MaxIterations
+	if maxIterations < 1 {
+		maxIterations = 1
 	}
-	
-	return nil
+
+	for attempt := 1; ; attempt++ {
+		// 3. Validate and execute
+		result, err := a.execute(ctx, files)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = a.quarantine(ctx, result); err != nil {
+			return nil, err
+		}
+
+		if result.Passed() {
+			fmt.Printf("[Agent] Tests passed on attempt %d/%d: %s\n", attempt, maxIterations, result.Summary())
+			return result, nil
+		}
+
+		if attempt >= maxIterations {
+			return result, fmt.Errorf("generated tests still failing after %d attempt(s)", attempt)
+		}
+
+		// 4. Repair
+		fmt.Printf("[Agent] Tests failed on attempt %d/%d (%s), asking coder to repair...\n", attempt, maxIterations, result.Summary())
+		files, err = a.repair(ctx, plan, fileContent, result.Files, result.Feedback())
+		if err != nil {
+			return result, fmt.Errorf("repair failed: %w", err)
+		}
+	}
+}
+
+// execute validates files, if a Validator is set, and runs them unless
+// problems were found.
+func (a *Agent) execute(ctx context.Context, files []Artifact) (*ExecutionResult, error) {
+	if a.Validator != nil {
+		fixed, diags, err := a.Validator.Validate(ctx, a.Workspace, files)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		if len(diags) > 0 {
+			fmt.Printf("[Agent] Found %d problem(s) in the generated code, skipping execution\n", len(diags))
+			return &ExecutionResult{Files: fixed, Diagnostics: diags}, nil
+		}
+		files = fixed
+	}
+
+	result, err := a.Executor.Execute(ctx, a.Workspace, files)
+	if err != nil {
+		return nil, fmt.Errorf("execution failed: %w", err)
+	}
+	if result.Files == nil {
+		result.Files = files
+	}
+	return result, nil
+}
+
+// quarantine skips the flaky tests of a run whose only failures are flaky
+// and runs the suite again, returning that run. Any other run is returned
+// as is.
+func (a *Agent) quarantine(ctx context.Context, result *ExecutionResult) (*ExecutionResult, error) {
+	flaky := result.Flaky()
+	if a.Quarantiner == nil || len(flaky) == 0 || len(result.Failed()) > 0 || result.TimedOut {
+		return result, nil
+	}
+
+	names := make([]string, len(flaky))
+	for i, t := range flaky {
+		names[i] = t.Name
+	}
+	files, err := a.Quarantiner.Quarantine(result.Files, flaky)
+	if err != nil {
+		fmt.Printf("[Agent] Failed to quarantine flaky tests: %v\n", err)
+		return result, nil
+	}
+	fmt.Printf("[Agent] Quarantining %d flaky test(s): %s\n", len(flaky), strings.Join(names, ", "))
+
+	rerun, err := a.execute(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	rerun.Quarantined = flaky
+	return rerun, nil
+}
+
+func (a *Agent) plan(ctx context.Context, repoContext string) (*TestPlan, error) {
+	ctx, cancel := withTimeout(ctx, a.PlanTimeout)
+	defer cancel()
+	plan, err := a.Planner.Plan(ctx, repoContext)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Plan runs only the planning stage.
+func (a *Agent) Plan(ctx context.Context, repoContext string) (*TestPlan, error) {
+	return a.plan(ctx, repoContext)
+}
+
+// Baseline executes the workspace's own tests without any generated files,
+// e.g. to measure the coverage that generated tests add. A failing run is
+// reported through the result.
+func (a *Agent) Baseline(ctx context.Context) (*ExecutionResult, error) {
+	result, err := a.Executor.Execute(ctx, a.Workspace, nil)
+	if err != nil {
+		return nil, fmt.Errorf("baseline execution failed: %w", err)
+	}
+	return result, nil
+}
+
+// Check validates and executes files as they are, without repairing them,
+// e.g. to run the tests generated for several targets together.
+func (a *Agent) Check(ctx context.Context, files []Artifact) (*ExecutionResult, error) {
+	return a.execute(ctx, files)
+}
+
+func (a *Agent) generate(ctx context.Context, plan *TestPlan, fileContent string) ([]Artifact, error) {
+	ctx, cancel := withTimeout(ctx, a.CodeTimeout)
+	defer cancel()
+	files, err := a.Coder.GenerateCode(ctx, plan, fileContent)
+	if err != nil {
+		return nil, err
+	}
+	return files, ValidateArtifacts(files)
+}
+
+func (a *Agent) repair(ctx context.Context, plan *TestPlan, fileContent string, previous []Artifact, feedback string) ([]Artifact, error) {
+	ctx, cancel := withTimeout(ctx, a.CodeTimeout)
+	defer cancel()
+	var (
+		files []Artifact
+		err   error
+	)
+	if r, ok := a.Coder.(Repairer); ok {
+		files, err = r.RepairCode(ctx, plan, fileContent, previous, feedback)
+	} else {
+		files, err = a.Coder.GenerateCode(ctx, RepairPlan(plan, previous, feedback), fileContent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return files, ValidateArtifacts(files)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// RepairPlan returns a copy of plan whose notes carry the previous attempt
+// and its executor output, so that any coder can be asked to fix its own
+// tests.
+func RepairPlan(plan *TestPlan, previous []Artifact, feedback string) *TestPlan {
+	repaired := *plan
+	if repaired.Notes != "" {
+		repaired.Notes += "\n\n"
+	}
+	repaired.Notes += fmt.Sprintf(`The previously generated test code did not pass. Fix it so that it compiles and the tests pass.
+Return every file again, complete and corrected.
+
+Previous code:
+%s
+Executor output:
+%s`, FormatArtifacts(previous), feedback)
+	return &repaired
 }
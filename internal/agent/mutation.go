@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CommandExecutor is implemented by executors that can run a command other
+// than their configured test command, e.g. to test mutants. The command is
+// given at least timeout to run, or the configured timeout if that is
+// longer.
+type CommandExecutor interface {
+	ExecuteCommand(ctx context.Context, ws Workspace, files []Artifact, command []string, timeout time.Duration) (*ExecutionResult, error)
+}
+
+// MutationTester checks whether the generated tests notice small changes
+// (mutants) to the code under test.
+type MutationTester interface {
+	// Prepare returns the files and command that run the tests against
+	// every mutant, next to the generated files.
+	Prepare(ctx context.Context) ([]Artifact, []string, error)
+
+	// Timeout is how long the command of the last Prepare may take to get
+	// through every mutant.
+	Timeout() time.Duration
+
+	// Report reads the outcome of each mutant from the run of the command.
+	Report(result *ExecutionResult) (*MutationReport, error)
+}
+
+// Mutant is a small change to the code under test.
+type Mutant struct {
+	ID       int
+	File     string
+	Line     int
+	Function string
+
+	// Original and Mutated are the changed code before and after
+	Original string
+	Mutated  string
+}
+
+func (m Mutant) String() string {
+	s := fmt.Sprintf("%s:%d", m.File, m.Line)
+	if m.Function != "" {
+		s += fmt.Sprintf(" (%s)", m.Function)
+	}
+	return fmt.Sprintf("%s: `%s` changed to `%s`", s, m.Original, m.Mutated)
+}
+
+// MutationReport is the outcome of running the tests against the mutants.
+type MutationReport struct {
+	// Killed counts the mutants that made a test fail
+	Killed int
+
+	// Survived are the mutants that every test passed against
+	Survived []Mutant
+
+	// Invalid counts the mutants that did not compile, NotRun those the
+	// run did not get to, e.g. because it timed out
+	Invalid int
+	NotRun  int
+}
+
+// Score returns the share of the compiling mutants that were killed.
+func (r *MutationReport) Score() float64 {
+	total := r.Killed + len(r.Survived)
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(r.Killed) / float64(total)
+}
+
+func (r *MutationReport) String() string {
+	s := fmt.Sprintf("mutation score %.1f%% (%d killed, %d survived", r.Score(), r.Killed, len(r.Survived))
+	if r.Invalid > 0 {
+		s += fmt.Sprintf(", %d did not compile", r.Invalid)
+	}
+	s += ")"
+	if r.NotRun > 0 {
+		// The score says nothing about these
+		s += fmt.Sprintf("; %d mutant(s) not run", r.NotRun)
+	}
+	return s
+}
+
+// strengthen scores passing tests against mutants of the code under test
+// and, while the score is below MutationTarget, asks the coder to make the
+// tests kill the mutants that survived, for at most MutationRounds rounds.
+// A round that fails or kills no more mutants ends the loop, keeping the
+// tests that passed before it. The report of the returned tests is set on
+// the result.
+func (a *Agent) strengthen(ctx context.Context, plan *TestPlan, fileContent string, result *ExecutionResult) (*ExecutionResult, error) {
+	if a.Mutation == nil {
+		return result, nil
+	}
+	if _, ok := a.Executor.(CommandExecutor); !ok || a.Workspace.SourceDir == "" {
+		fmt.Printf("[Agent] Mutation testing needs an executor that runs commands against the project source, skipping\n")
+		return result, nil
+	}
+
+	report, err := a.mutate(ctx, result.Files)
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, err
+		}
+		fmt.Printf("[Agent] Mutation testing failed: %v\n", err)
+		return result, nil
+	}
+	result.Mutation = report
+
+	for round := 1; ; round++ {
+		fmt.Printf("[Agent] Generated tests: %s\n", report)
+		switch {
+		case report.Score() >= a.MutationTarget, len(report.Survived) == 0:
+			return result, nil
+		case round > a.MutationRounds:
+			for _, m := range report.Survived {
+				fmt.Printf("[Agent] Surviving mutant %s\n", m)
+			}
+			return result, nil
+		}
+
+		fmt.Printf("[Agent] Mutation round %d/%d: asking coder to kill %d surviving mutant(s)...\n", round, a.MutationRounds, len(report.Survived))
+		next, nextReport, err := a.mutationRound(ctx, plan, fileContent, result, report)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, err
+			}
+			fmt.Printf("[Agent] Mutation round %d failed, keeping the passing tests: %v\n", round, err)
+			return result, nil
+		}
+		if nextReport.Killed <= report.Killed {
+			fmt.Printf("[Agent] Mutation round %d killed no more mutants, keeping the previous tests\n", round)
+			return result, nil
+		}
+		next.Mutation = nextReport
+		result, report = next, nextReport
+	}
+}
+
+// mutationRound has the coder strengthen the tests against the surviving
+// mutants of report, runs them through the repair loop and scores them.
+func (a *Agent) mutationRound(ctx context.Context, plan *TestPlan, fileContent string, result *ExecutionResult, report *MutationReport) (*ExecutionResult, *MutationReport, error) {
+	files, err := a.generate(ctx, StrengthenPlan(plan, result.Files, report.Survived), fileContent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("code generation failed: %w", err)
+	}
+	next, err := a.attempt(ctx, plan, fileContent, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	nextReport, err := a.mutate(ctx, next.Files)
+	if err != nil {
+		return nil, nil, err
+	}
+	return next, nextReport, nil
+}
+
+// mutate runs files against every mutant of the code under test.
+func (a *Agent) mutate(ctx context.Context, files []Artifact) (*MutationReport, error) {
+	extra, command, err := a.Mutation.Prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all := append(append([]Artifact(nil), files...), extra...)
+	run, err := a.Executor.(CommandExecutor).ExecuteCommand(ctx, a.Workspace, all, command, a.Mutation.Timeout())
+	if err != nil {
+		return nil, fmt.Errorf("execution failed: %w", err)
+	}
+	return a.Mutation.Report(run)
+}
+
+// StrengthenPlan returns a copy of plan whose notes ask the coder to make
+// the passing tests in previous fail against the surviving mutants.
+func StrengthenPlan(plan *TestPlan, previous []Artifact, survived []Mutant) *TestPlan {
+	var mutants strings.Builder
+	for _, m := range survived {
+		fmt.Fprintf(&mutants, "- %s\n", m)
+	}
+
+	strengthened := *plan
+	if strengthened.Notes != "" {
+		strengthened.Notes += "\n\n"
+	}
+	strengthened.Notes += fmt.Sprintf(`The code below passes, but it still passes when the code under test is changed as follows, so it does not check that behaviour:
+%s
+Keep the existing tests and strengthen their assertions, or add tests, so that each of these changes would make a test fail. Do not test the changed code itself; test the behaviour it implements.
+Return every file again, complete.
+
+Existing code:
+%s`, mutants.String(), FormatArtifacts(previous))
+	return &strengthened
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type commandExecutor struct {
+	scriptedExecutor
+	commands [][]string
+	files    [][]Artifact
+	timeouts []time.Duration
+}
+
+func (e *commandExecutor) ExecuteCommand(ctx context.Context, ws Workspace, files []Artifact, command []string, timeout time.Duration) (*ExecutionResult, error) {
+	e.commands = append(e.commands, command)
+	e.files = append(e.files, files)
+	e.timeouts = append(e.timeouts, timeout)
+	return &ExecutionResult{}, nil
+}
+
+type scriptedTester struct {
+	reports []*MutationReport
+}
+
+func (m *scriptedTester) Prepare(context.Context) ([]Artifact, []string, error) {
+	return []Artifact{{Path: ".mutants/1.go", Content: "mutant"}}, []string{"sh", "run.sh"}, nil
+}
+
+func (m *scriptedTester) Timeout() time.Duration {
+	return 20 * time.Minute
+}
+
+func (m *scriptedTester) Report(*ExecutionResult) (*MutationReport, error) {
+	r := m.reports[0]
+	m.reports = m.reports[1:]
+	return r, nil
+}
+
+func TestRun_Mutation(t *testing.T) {
+	survivor := Mutant{ID: 2, File: "cart/cart.go", Line: 5, Function: "Discount", Original: "return 0", Mutated: "(removed)"}
+	c := &fakeCoder{}
+	e := &commandExecutor{scriptedExecutor: scriptedExecutor{results: []*ExecutionResult{{}}}}
+	a := NewAgent(fakePlanner{}, c, e)
+	a.Workspace = Workspace{SourceDir: "/src", TestDir: "cart"}
+	a.Mutation = &scriptedTester{reports: []*MutationReport{
+		{Killed: 1, Survived: []Mutant{survivor}},
+		{Killed: 2},
+	}}
+	a.MutationTarget = 100
+	a.MutationRounds = 1
+
+	result, err := a.Run(context.Background(), "ctx", "file")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Mutation == nil || result.Mutation.Killed != 2 || result.Mutation.Score() != 100 {
+		t.Errorf("expected the strengthened tests' report, got %+v", result.Mutation)
+	}
+
+	if len(e.commands) != 2 || strings.Join(e.commands[0], " ") != "sh run.sh" {
+		t.Errorf("expected the mutation command for each scoring, got %v", e.commands)
+	}
+	if e.timeouts[0] != 20*time.Minute {
+		t.Errorf("expected the tester's timeout for the run, got %v", e.timeouts)
+	}
+	if files := e.files[0]; len(files) != 2 || files[0].Content != "code" || files[1].Path != ".mutants/1.go" {
+		t.Errorf("expected the generated files next to the mutants, got %+v", files)
+	}
+
+	if len(c.generated) != 2 || !strings.Contains(c.generated[1].Notes, "cart/cart.go:5 (Discount): `return 0` changed to `(removed)`") {
+		t.Errorf("expected the surviving mutant fed back to the coder, got %+v", c.generated)
+	}
+}
+
+func TestMutationReport_Score(t *testing.T) {
+	r := &MutationReport{Killed: 3, Survived: []Mutant{{}}, Invalid: 2}
+	if r.Score() != 75 {
+		t.Errorf("expected invalid mutants to be left out of the score, got %v", r.Score())
+	}
+	if got := r.String(); got != "mutation score 75.0% (3 killed, 1 survived, 2 did not compile)" {
+		t.Errorf("unexpected summary %q", got)
+	}
+
+	r.NotRun = 4
+	if r.Score() != 75 {
+		t.Errorf("expected mutants that were not run to be left out of the score, got %v", r.Score())
+	}
+	if got := r.String(); got != "mutation score 75.0% (3 killed, 1 survived, 2 did not compile); 4 mutant(s) not run" {
+		t.Errorf("unexpected summary %q", got)
+	}
+}
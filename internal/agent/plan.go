@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Priority ranks scenarios so that coders and budgets can focus on the most
+// valuable tests first.
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityMedium Priority = "medium"
+	PriorityLow    Priority = "low"
+)
+
+// TestPlan is the structured output of a Planner and the input of a Coder.
+type TestPlan struct {
+	// Summary describes the overall testing strategy
+	Summary string `json:"summary"`
+
+	// Framework is the test framework to use, e.g. "go test", "playwright"
+	Framework string `json:"framework,omitempty"`
+
+	// TargetFiles lists the files under test
+	TargetFiles []string `json:"target_files,omitempty"`
+
+	Scenarios []Scenario `json:"scenarios"`
+
+	// Notes carries extra instructions for the coder, such as the failure
+	// output of a previous attempt. Planners leave it empty.
+	Notes string `json:"notes,omitempty"`
+}
+
+// Scenario is a single behaviour to test.
+type Scenario struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	TargetFile  string   `json:"target_file,omitempty"`
+	Function    string   `json:"function,omitempty"`
+	Priority    Priority `json:"priority,omitempty"`
+	EdgeCases   []string `json:"edge_cases,omitempty"`
+	Assertions  []string `json:"assertions,omitempty"`
+}
+
+// ParseTestPlan decodes and validates a JSON test plan. Surrounding Markdown
+// code fences, which models often add, are ignored.
+func ParseTestPlan(data []byte) (*TestPlan, error) {
+	s := strings.TrimSpace(string(data))
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+
+	var plan TestPlan
+	if err := json.Unmarshal([]byte(s), &plan); err != nil {
+		return nil, fmt.Errorf("invalid test plan JSON: %w", err)
+	}
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// Validate checks that the plan has at least one uniquely named scenario and
+// that priorities are known.
+func (p *TestPlan) Validate() error {
+	if len(p.Scenarios) == 0 {
+		return errors.New("invalid test plan: no scenarios")
+	}
+
+	seen := map[string]bool{}
+	for i, s := range p.Scenarios {
+		if strings.TrimSpace(s.Name) == "" {
+			return fmt.Errorf("invalid test plan: scenario %d has no name", i+1)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("invalid test plan: duplicate scenario %q", s.Name)
+		}
+		seen[s.Name] = true
+
+		switch s.Priority {
+		case "", PriorityHigh, PriorityMedium, PriorityLow:
+		default:
+			return fmt.Errorf("invalid test plan: scenario %q has unknown priority %q", s.Name, s.Priority)
+		}
+	}
+	return nil
+}
+
+// SplitByTarget returns one plan per scenario target file, in order of first
+// appearance, so that each can be sent to a separate coder call. Scenarios
+// without a target file are grouped together.
+func (p *TestPlan) SplitByTarget() []*TestPlan {
+	var (
+		order []string
+		plans = map[string]*TestPlan{}
+	)
+	for _, s := range p.Scenarios {
+		sub, ok := plans[s.TargetFile]
+		if !ok {
+			sub = &TestPlan{
+				Summary:   p.Summary,
+				Framework: p.Framework,
+				Notes:     p.Notes,
+			}
+			if s.TargetFile != "" {
+				sub.TargetFiles = []string{s.TargetFile}
+			} else {
+				sub.TargetFiles = p.TargetFiles
+			}
+			plans[s.TargetFile] = sub
+			order = append(order, s.TargetFile)
+		}
+		sub.Scenarios = append(sub.Scenarios, s)
+	}
+
+	out := make([]*TestPlan, 0, len(order))
+	for _, target := range order {
+		out = append(out, plans[target])
+	}
+	return out
+}
+
+// ByPriority returns the scenarios ordered high, medium (or unset), low,
+// keeping the planner's order within each priority.
+func (p *TestPlan) ByPriority() []Scenario {
+	rank := func(pr Priority) int {
+		switch pr {
+		case PriorityHigh:
+			return 0
+		case PriorityLow:
+			return 2
+		default:
+			return 1
+		}
+	}
+	out := append([]Scenario(nil), p.Scenarios...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return rank(out[i].Priority) < rank(out[j].Priority)
+	})
+	return out
+}
+
+// String renders the plan as Markdown for use in coder prompts and logs.
+func (p *TestPlan) String() string {
+	var b strings.Builder
+	if p.Summary != "" {
+		fmt.Fprintf(&b, "%s\n", p.Summary)
+	}
+	if p.Framework != "" {
+		fmt.Fprintf(&b, "\nFramework: %s\n", p.Framework)
+	}
+	if len(p.TargetFiles) > 0 {
+		fmt.Fprintf(&b, "Target files: %s\n", strings.Join(p.TargetFiles, ", "))
+	}
+
+	for _, s := range p.ByPriority() {
+		fmt.Fprintf(&b, "\n## %s", s.Name)
+		if s.Priority != "" {
+			fmt.Fprintf(&b, " (%s priority)", s.Priority)
+		}
+		b.WriteString("\n")
+		if s.Description != "" {
+			fmt.Fprintf(&b, "%s\n", s.Description)
+		}
+		if s.Function != "" || s.TargetFile != "" {
+			fmt.Fprintf(&b, "Under test: %s\n", strings.Trim(s.TargetFile+" "+s.Function, " "))
+		}
+		for _, e := range s.EdgeCases {
+			fmt.Fprintf(&b, "- Edge case: %s\n", e)
+		}
+		for _, a := range s.Assertions {
+			fmt.Fprintf(&b, "- Assert: %s\n", a)
+		}
+	}
+
+	if p.Notes != "" {
+		fmt.Fprintf(&b, "\n%s\n", p.Notes)
+	}
+	return b.String()
+}
+
+// TestPlanJSONSchema is the JSON Schema of TestPlan, used to constrain the
+// output of planners that support structured responses.
+const TestPlanJSONSchema = `{
+  "type": "object",
+  "properties": {
+    "summary": {"type": "string"},
+    "framework": {"type": "string"},
+    "target_files": {"type": "array", "items": {"type": "string"}},
+    "scenarios": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "description": {"type": "string"},
+          "target_file": {"type": "string"},
+          "function": {"type": "string"},
+          "priority": {"type": "string", "enum": ["high", "medium", "low"]},
+          "edge_cases": {"type": "array", "items": {"type": "string"}},
+          "assertions": {"type": "array", "items": {"type": "string"}}
+        },
+        "required": ["name", "description"]
+      }
+    }
+  },
+  "required": ["summary", "scenarios"]
+}`
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTestPlan_RoundTrip(t *testing.T) {
+	plan := &TestPlan{
+		Summary:     "Cover auth",
+		Framework:   "go test",
+		TargetFiles: []string{"auth.go"},
+		Scenarios: []Scenario{{
+			Name:        "bad password",
+			Description: "Login rejects a wrong password",
+			TargetFile:  "auth.go",
+			Function:    "Login",
+			Priority:    PriorityHigh,
+			EdgeCases:   []string{"empty password"},
+			Assertions:  []string{"returns ErrUnauthorized"},
+		}},
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	got, err := ParseTestPlan(data)
+	if err != nil {
+		t.Fatalf("ParseTestPlan failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, plan) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, plan)
+	}
+}
+
+func TestParseTestPlan_CodeFence(t *testing.T) {
+	got, err := ParseTestPlan([]byte("```json\n{\"summary\":\"s\",\"scenarios\":[{\"name\":\"a\",\"description\":\"d\"}]}\n```\n"))
+	if err != nil {
+		t.Fatalf("ParseTestPlan failed: %v", err)
+	}
+	if len(got.Scenarios) != 1 || got.Scenarios[0].Name != "a" {
+		t.Errorf("unexpected plan %+v", got)
+	}
+}
+
+func TestTestPlan_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		plan TestPlan
+		want string
+	}{
+		{"no scenarios", TestPlan{Summary: "s"}, "no scenarios"},
+		{"unnamed", TestPlan{Scenarios: []Scenario{{Description: "d"}}}, "has no name"},
+		{"duplicate", TestPlan{Scenarios: []Scenario{{Name: "a"}, {Name: "a"}}}, "duplicate scenario"},
+		{"priority", TestPlan{Scenarios: []Scenario{{Name: "a", Priority: "urgent"}}}, "unknown priority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plan.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestTestPlan_SplitByTarget(t *testing.T) {
+	plan := &TestPlan{
+		Summary:     "s",
+		TargetFiles: []string{"a.go", "b.go"},
+		Scenarios: []Scenario{
+			{Name: "a1", TargetFile: "a.go"},
+			{Name: "b1", TargetFile: "b.go"},
+			{Name: "a2", TargetFile: "a.go"},
+			{Name: "general"},
+		},
+	}
+
+	parts := plan.SplitByTarget()
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 plans, got %d", len(parts))
+	}
+	if parts[0].TargetFiles[0] != "a.go" || len(parts[0].Scenarios) != 2 || parts[0].Scenarios[1].Name != "a2" {
+		t.Errorf("unexpected first plan %+v", parts[0])
+	}
+	if parts[1].TargetFiles[0] != "b.go" || len(parts[1].Scenarios) != 1 {
+		t.Errorf("unexpected second plan %+v", parts[1])
+	}
+	if len(parts[2].TargetFiles) != 2 || parts[2].Scenarios[0].Name != "general" {
+		t.Errorf("expected untargeted scenarios to keep the plan's files, got %+v", parts[2])
+	}
+	for _, p := range parts {
+		if err := p.Validate(); err != nil {
+			t.Errorf("split plan is invalid: %v", err)
+		}
+	}
+}
+
+func TestTestPlan_String(t *testing.T) {
+	plan := &TestPlan{
+		Summary: "Cover auth",
+		Scenarios: []Scenario{
+			{Name: "nice to have", Priority: PriorityLow},
+			{Name: "bad password", Function: "Login", Priority: PriorityHigh, Assertions: []string{"returns ErrUnauthorized"}},
+		},
+	}
+
+	s := plan.String()
+	if strings.Index(s, "bad password") > strings.Index(s, "nice to have") {
+		t.Errorf("expected high priority scenarios first, got:\n%s", s)
+	}
+	if !strings.Contains(s, "Under test: Login") || !strings.Contains(s, "- Assert: returns ErrUnauthorized") {
+		t.Errorf("expected function and assertions in rendering, got:\n%s", s)
+	}
+}
@@ -0,0 +1,307 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TestStatus is the outcome of a single test.
+type TestStatus string
+
+const (
+	TestPassed  TestStatus = "pass"
+	TestFailed  TestStatus = "fail"
+	TestSkipped TestStatus = "skip"
+
+	// TestFlaky marks a test that both passed and failed over repeated runs
+	TestFlaky TestStatus = "flaky"
+)
+
+// TestOutcome is the result of one test reported by the test runner.
+type TestOutcome struct {
+	Package string
+	Name    string
+	Status  TestStatus
+	Elapsed time.Duration
+	Output  string
+
+	// Runs and Failures count the runs of a repeated test and those it
+	// failed; both are zero for a single run
+	Runs     int
+	Failures int
+}
+
+// PackageOutcome is the result of one test package, as reported by runners
+// that group tests into packages (e.g. go test).
+type PackageOutcome struct {
+	Name    string
+	Status  TestStatus
+	Elapsed time.Duration
+	Output  string
+}
+
+// ExecutionResult describes a single run of generated tests in an executor.
+type ExecutionResult struct {
+	ExitCode    int
+	Stdout      string
+	Stderr      string
+	Duration    time.Duration
+	TimedOut    bool
+	ImageDigest string
+	Tests       []TestOutcome
+	Packages    []PackageOutcome
+
+	// Files are the generated files that were run, with unnamed files given
+	// the path the executor wrote them to
+	Files []Artifact
+
+	// Diagnostics are the problems found by the Validator. The tests are not
+	// executed when there are any.
+	Diagnostics []Diagnostic
+
+	// Coverage holds the blocks of the coverage profile written by the run,
+	// if the executor measured coverage
+	Coverage []CoverBlock
+
+	// Mutation is the mutation testing report of the files, if they were
+	// tested against mutants of the code under test
+	Mutation *MutationReport
+
+	// Runs is the number of times the suite was run to detect flaky tests,
+	// zero for a single run
+	Runs int
+
+	// Quarantined are the flaky tests that were skipped in Files
+	Quarantined []TestOutcome
+}
+
+// CoverBlock is one block of a Go coverage profile: a range of statements
+// and how often it ran.
+type CoverBlock struct {
+	// File is the file as named in the profile, i.e. its import path
+	// followed by the file name
+	File      string
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+
+	Statements int
+	Count      int
+}
+
+// Diagnostic is a problem found in generated code before execution.
+type Diagnostic struct {
+	// File is the path of the generated file
+	File   string
+	Line   int
+	Column int
+
+	// Source names the check that found the problem, e.g. "syntax" or "vet"
+	Source  string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos += fmt.Sprintf(":%d", d.Line)
+		if d.Column > 0 {
+			pos += fmt.Sprintf(":%d", d.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s (%s)", pos, d.Message, d.Source)
+}
+
+// Passed reports whether the generated code was valid and the test command
+// exited cleanly within its timeout.
+func (r *ExecutionResult) Passed() bool {
+	return r.ExitCode == 0 && !r.TimedOut && len(r.Diagnostics) == 0
+}
+
+// Failed returns the tests that did not pass.
+func (r *ExecutionResult) Failed() []TestOutcome {
+	var failed []TestOutcome
+	for _, t := range r.Tests {
+		if t.Status == TestFailed {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// Flaky returns the tests that both passed and failed over repeated runs.
+func (r *ExecutionResult) Flaky() []TestOutcome {
+	var flaky []TestOutcome
+	for _, t := range r.Tests {
+		if t.Status == TestFlaky {
+			flaky = append(flaky, t)
+		}
+	}
+	return flaky
+}
+
+// ClassifyRuns merges the outcomes of a suite that was run several times
+// into one outcome per test, in order of first appearance. A test that
+// always passed or always failed keeps that status; one that did both is
+// flaky and keeps the output of its first failure. Skipped runs count
+// neither way.
+func ClassifyRuns(tests []TestOutcome) []TestOutcome {
+	type key struct{ pkg, name string }
+	var (
+		merged []TestOutcome
+		index  = map[key]int{}
+		passed = map[key]bool{}
+	)
+	for _, t := range tests {
+		k := key{t.Package, t.Name}
+		i, ok := index[k]
+		if !ok {
+			i = len(merged)
+			index[k] = i
+			merged = append(merged, TestOutcome{Package: t.Package, Name: t.Name, Status: TestSkipped})
+		}
+		m := &merged[i]
+		m.Runs++
+		m.Elapsed = max(m.Elapsed, t.Elapsed)
+		switch t.Status {
+		case TestPassed:
+			passed[k] = true
+		case TestFailed:
+			if m.Failures == 0 {
+				m.Output = t.Output
+			}
+			m.Failures++
+		}
+	}
+
+	for i := range merged {
+		m := &merged[i]
+		passedOnce := passed[key{m.Package, m.Name}]
+		switch {
+		case m.Failures > 0 && passedOnce:
+			m.Status = TestFlaky
+		case m.Failures > 0:
+			m.Status = TestFailed
+		case passedOnce:
+			m.Status = TestPassed
+		}
+	}
+	return merged
+}
+
+// Output returns the combined stdout and stderr of the run.
+func (r *ExecutionResult) Output() string {
+	output := r.Stdout
+	if r.Stderr != "" {
+		output += "\n--- STDERR ---\n" + r.Stderr
+	}
+	return output
+}
+
+// Summary returns a short human-readable description of the run, suitable
+// for logs and for feeding back to a coder.
+func (r *ExecutionResult) Summary() string {
+	var b strings.Builder
+	if len(r.Diagnostics) > 0 {
+		fmt.Fprintf(&b, "not executed, %d problem(s) found before execution", len(r.Diagnostics))
+		for _, d := range r.Diagnostics {
+			fmt.Fprintf(&b, "\n%s", d)
+		}
+		return b.String()
+	}
+
+	switch {
+	case r.TimedOut:
+		fmt.Fprintf(&b, "timed out after %s", r.Duration.Round(time.Millisecond))
+	default:
+		fmt.Fprintf(&b, "exit code %d after %s", r.ExitCode, r.Duration.Round(time.Millisecond))
+	}
+
+	if r.Runs > 1 {
+		fmt.Fprintf(&b, " over %d runs", r.Runs)
+	}
+
+	var passed, failed, skipped, flaky int
+	for _, t := range r.Tests {
+		switch t.Status {
+		case TestPassed:
+			passed++
+		case TestFailed:
+			failed++
+		case TestSkipped:
+			skipped++
+		case TestFlaky:
+			flaky++
+		}
+	}
+	if len(r.Tests) > 0 {
+		fmt.Fprintf(&b, ", %d passed, %d failed, %d skipped", passed, failed, skipped)
+		if flaky > 0 {
+			fmt.Fprintf(&b, ", %d flaky", flaky)
+		}
+	}
+	for _, t := range r.Failed() {
+		fmt.Fprintf(&b, "\nFAILED: %s", t.Name)
+		if t.Package != "" {
+			fmt.Fprintf(&b, " (%s)", t.Package)
+		}
+	}
+	for _, t := range r.Flaky() {
+		fmt.Fprintf(&b, "\nFLAKY: %s, failed %d of %d runs", t.Name, t.Failures, t.Runs)
+	}
+	for _, t := range r.Quarantined {
+		fmt.Fprintf(&b, "\nQUARANTINED: %s (flaky, failed %d of %d runs)", t.Name, t.Failures, t.Runs)
+	}
+	for _, p := range r.Packages {
+		if p.Status == TestFailed {
+			fmt.Fprintf(&b, "\nFAILED PACKAGE: %s", p.Name)
+		}
+	}
+	return b.String()
+}
+
+// Feedback returns the summary followed by the captured output of each failed
+// or flaky test and failed package. When the runner did not report per-test
+// output it falls back to the raw output of the run.
+func (r *ExecutionResult) Feedback() string {
+	var b strings.Builder
+	b.WriteString(r.Summary())
+	if len(r.Diagnostics) > 0 {
+		return b.String()
+	}
+
+	detailed := false
+	for _, t := range r.Failed() {
+		if t.Output == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n=== %s ===\n%s", t.Name, t.Output)
+		detailed = true
+	}
+	for _, t := range r.Flaky() {
+		if t.Output == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n=== %s (flaky) ===\n%s", t.Name, t.Output)
+		detailed = true
+	}
+	for _, p := range r.Packages {
+		if p.Status != TestFailed || p.Output == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n=== package %s ===\n%s", p.Name, p.Output)
+		detailed = true
+	}
+
+	switch {
+	case !detailed:
+		b.WriteString("\n\n")
+		b.WriteString(r.Output())
+	case r.Stderr != "":
+		b.WriteString("\n\n--- STDERR ---\n")
+		b.WriteString(r.Stderr)
+	}
+	return b.String()
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,6 +17,46 @@ type Profile struct {
 	Planner  ProviderConfig `mapstructure:"planner"`
 	Coder    ProviderConfig `mapstructure:"coder"`
 	Executor ProviderConfig `mapstructure:"executor"`
+	Agent    AgentConfig    `mapstructure:"agent"`
+}
+
+// AgentConfig tunes the orchestration loop of a profile.
+type AgentConfig struct {
+	// MaxIterations bounds how many times failing tests are fed back to the
+	// coder for repair, including the first attempt.
+	MaxIterations int `mapstructure:"max_iterations"`
+
+	// RunTimeout bounds the whole run; PlanTimeout and CodeTimeout bound each
+	// planner and coder call (e.g. "10m", "90s"). Zero means no limit.
+	RunTimeout  time.Duration `mapstructure:"run_timeout"`
+	PlanTimeout time.Duration `mapstructure:"plan_timeout"`
+	CodeTimeout time.Duration `mapstructure:"code_timeout"`
+
+	// ContextTokens bounds the estimated size of the repository context sent
+	// to the planner. Zero uses repocontext.DefaultTokenBudget.
+	ContextTokens int `mapstructure:"context_tokens"`
+
+	// Validate checks generated Go code before it is executed: syntax,
+	// imports, formatting and go vet. Problems are fed back to the coder
+	// without starting a container.
+	Validate bool `mapstructure:"validate"`
+
+	// CoverageTarget, a percentage above zero, turns on coverage-guided
+	// planning for Go targets: after the tests pass, the planner is asked
+	// for scenarios aimed at the uncovered lines of the functions under
+	// test until they reach the target, for at most CoverageRounds rounds
+	// (default 3). It requires the executor's coverage param.
+	CoverageTarget float64 `mapstructure:"coverage_target"`
+	CoverageRounds int     `mapstructure:"coverage_rounds"`
+
+	// Mutation scores passing Go tests against mutants of the functions
+	// under test, at most MaxMutants of them (default 30). While the score
+	// is below MutationTarget (default 100), surviving mutants are fed back
+	// to the coder for at most MutationRounds rounds (default 1).
+	Mutation       bool    `mapstructure:"mutation"`
+	MutationTarget float64 `mapstructure:"mutation_target"`
+	MutationRounds int     `mapstructure:"mutation_rounds"`
+	MaxMutants     int     `mapstructure:"max_mutants"`
 }
 
 type ProviderConfig struct {
@@ -46,3 +88,18 @@ func LoadConfig(path string) (*Config, error) {
 
 	return &cfg, nil
 }
+
+// GetProfile returns the named profile, or an error listing the available
+// profiles when it is not defined.
+func (c *Config) GetProfile(name string) (Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("profile %q not found (available: %s)", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
@@ -0,0 +1,109 @@
+package coverage
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/repocontext"
+)
+
+// Analyzer measures the coverage of the functions under test in one file.
+// It implements agent.CoverageAnalyzer.
+type Analyzer struct {
+	repo *repocontext.Repo
+	file string
+
+	// profileFile is the name of the file in coverage profiles
+	profileFile string
+	functions   map[string]bool
+}
+
+// NewAnalyzer returns an Analyzer for the named functions and methods
+// ("Type.Method") of the repo-relative Go file, or for all of its functions
+// if none are named.
+func NewAnalyzer(repo *repocontext.Repo, file string, functions []string) (*Analyzer, error) {
+	file = filepath.ToSlash(file)
+	pkg := repo.Package(file)
+	if pkg == nil {
+		return nil, fmt.Errorf("%s is not in a Go package", file)
+	}
+	a := &Analyzer{
+		repo:        repo,
+		file:        file,
+		profileFile: pkg.ImportPath + "/" + path.Base(file),
+	}
+	if len(functions) > 0 {
+		a.functions = map[string]bool{}
+		for _, name := range functions {
+			a.functions[name] = true
+		}
+	}
+	return a, nil
+}
+
+// Analyze implements agent.CoverageAnalyzer. Uncovered blocks on adjacent
+// or overlapping lines of a function are reported as one gap. A profile
+// without blocks for the functions under test is an error rather than full
+// coverage, since it means the measurement missed them.
+func (a *Analyzer) Analyze(blocks []agent.CoverBlock) (*agent.CoverageStatus, error) {
+	var fileBlocks []agent.CoverBlock
+	for _, b := range blocks {
+		if b.File == a.profileFile {
+			fileBlocks = append(fileBlocks, b)
+		}
+	}
+
+	filename := filepath.Join(a.repo.Root, filepath.FromSlash(a.file))
+	decls, err := parseFuncs(filename, a.file)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(src), "\n")
+
+	status := &agent.CoverageStatus{}
+	matched := 0
+	for _, d := range decls {
+		if a.functions != nil && !a.functions[d.Name] {
+			continue
+		}
+		var gaps []agent.CoverageGap
+		funcBlocks := sortedBlocks(d.blocks(fileBlocks))
+		matched += len(funcBlocks)
+		for _, b := range funcBlocks {
+			status.Statements += b.Statements
+			if b.Count > 0 {
+				status.Covered += b.Statements
+				continue
+			}
+			if n := len(gaps); n > 0 && b.StartLine <= gaps[n-1].EndLine+1 {
+				gaps[n-1].EndLine = max(gaps[n-1].EndLine, b.EndLine)
+				continue
+			}
+			gaps = append(gaps, agent.CoverageGap{File: a.file, Function: d.Name, StartLine: b.StartLine, EndLine: b.EndLine})
+		}
+		for i := range gaps {
+			gaps[i].Source = strings.Join(lines[gaps[i].StartLine-1:min(gaps[i].EndLine, len(lines))], "\n")
+		}
+		status.Gaps = append(status.Gaps, gaps...)
+	}
+	if matched == 0 {
+		return nil, fmt.Errorf("coverage profile has no blocks for the functions under test in %s (%s)", a.file, a.profileFile)
+	}
+	return status, nil
+}
+
+func sortedBlocks(blocks []agent.CoverBlock) []agent.CoverBlock {
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return before(blocks[i].StartLine, blocks[i].StartCol, blocks[j].StartLine, blocks[j].StartCol)
+	})
+	return blocks
+}
@@ -0,0 +1,216 @@
+// Package coverage maps the blocks of a Go coverage profile onto the
+// functions of a checkout and compares the coverage of two runs, e.g. the
+// project's own tests before and after generated tests were added.
+package coverage
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/goast"
+	"localsprite/internal/repocontext"
+)
+
+// Function is the coverage of one function or method.
+type Function struct {
+	// File is the slash-separated path relative to the repository root
+	File string
+
+	// Name is "Name" for functions and "Type.Name" for methods
+	Name string
+	Line int
+
+	Statements int
+	Covered    int
+}
+
+// Percent returns the share of the function's statements that ran.
+func (f Function) Percent() float64 {
+	return percent(f.Covered, f.Statements)
+}
+
+// Functions returns the coverage of every function in the files named by
+// the profile blocks, ordered by file and line. Files outside the
+// repository's packages, such as those of dependencies covered through
+// -coverpkg, are left out.
+func Functions(repo *repocontext.Repo, blocks []agent.CoverBlock) ([]Function, error) {
+	files := map[string]string{}
+	for _, pkg := range repo.Packages {
+		for _, name := range pkg.Files {
+			files[pkg.ImportPath+"/"+name] = path.Join(pkg.Dir, name)
+		}
+	}
+
+	byFile := map[string][]agent.CoverBlock{}
+	for _, b := range blocks {
+		if file, ok := files[b.File]; ok {
+			byFile[file] = append(byFile[file], b)
+		}
+	}
+	names := make([]string, 0, len(byFile))
+	for file := range byFile {
+		names = append(names, file)
+	}
+	sort.Strings(names)
+
+	var funcs []Function
+	for _, file := range names {
+		fileFuncs, err := fileFunctions(filepath.Join(repo.Root, filepath.FromSlash(file)), file, byFile[file])
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, fileFuncs...)
+	}
+	return funcs, nil
+}
+
+// fileFunctions attributes blocks to the functions of one file the way go
+// tool cover -func does: a block belongs to the function declaration whose
+// extent contains it, function literals included.
+func fileFunctions(filename, file string, blocks []agent.CoverBlock) ([]Function, error) {
+	decls, err := parseFuncs(filename, file)
+	if err != nil {
+		return nil, err
+	}
+	funcs := make([]Function, 0, len(decls))
+	for _, d := range decls {
+		c := d.Function
+		for _, b := range d.blocks(blocks) {
+			c.Statements += b.Statements
+			if b.Count > 0 {
+				c.Covered += b.Statements
+			}
+		}
+		funcs = append(funcs, c)
+	}
+	return funcs, nil
+}
+
+// funcDecl is a function declaration and its extent.
+type funcDecl struct {
+	Function
+	start, end token.Position
+}
+
+func parseFuncs(filename, file string) ([]funcDecl, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	var decls []funcDecl
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+		decls = append(decls, funcDecl{
+			Function: Function{File: file, Name: goast.FuncName(fn), Line: start.Line},
+			start:    start,
+			end:      end,
+		})
+	}
+	return decls, nil
+}
+
+// blocks returns the blocks within the extent of the declaration.
+func (d funcDecl) blocks(blocks []agent.CoverBlock) []agent.CoverBlock {
+	var in []agent.CoverBlock
+	for _, b := range blocks {
+		if before(b.StartLine, b.StartCol, d.start.Line, d.start.Column) || before(d.end.Line, d.end.Column, b.EndLine, b.EndCol) {
+			continue
+		}
+		in = append(in, b)
+	}
+	return in
+}
+
+// Change is the coverage of a function before and after a run.
+type Change struct {
+	Function
+
+	// Before is the number of statements covered before
+	Before int
+}
+
+// Gain returns the number of statements newly covered.
+func (c Change) Gain() int {
+	return c.Covered - c.Before
+}
+
+// Compare returns the functions whose coverage changed between two runs,
+// largest gain first. Functions missing from before, e.g. because their
+// package was not covered at all, count as uncovered.
+func Compare(before, after []Function) []Change {
+	prev := map[string]int{}
+	for _, f := range before {
+		prev[f.File+":"+f.Name] = f.Covered
+	}
+
+	var changes []Change
+	for _, f := range after {
+		c := Change{Function: f, Before: prev[f.File+":"+f.Name]}
+		if c.Gain() != 0 {
+			changes = append(changes, c)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Gain() != changes[j].Gain() {
+			return changes[i].Gain() > changes[j].Gain()
+		}
+		if changes[i].File != changes[j].File {
+			return changes[i].File < changes[j].File
+		}
+		return changes[i].Line < changes[j].Line
+	})
+	return changes
+}
+
+// Total returns the covered and total statements of funcs.
+func Total(funcs []Function) (covered, statements int) {
+	for _, f := range funcs {
+		covered += f.Covered
+		statements += f.Statements
+	}
+	return covered, statements
+}
+
+// Report describes the coverage before and after a run: the total, then
+// each function whose coverage changed, largest gain first.
+func Report(before, after []Function) string {
+	beforeCovered, beforeTotal := Total(before)
+	afterCovered, afterTotal := Total(after)
+	changes := Compare(before, after)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "total: %.1f%% -> %.1f%% of statements", percent(beforeCovered, beforeTotal), percent(afterCovered, afterTotal))
+	if len(changes) == 0 {
+		b.WriteString(", no change")
+	}
+	for _, c := range changes {
+		fmt.Fprintf(&b, "\n%s:%d: %s %.1f%% -> %.1f%% (%+d statements)",
+			c.File, c.Line, c.Name, percent(c.Before, c.Statements), c.Percent(), c.Gain())
+	}
+	return b.String()
+}
+
+func percent(covered, statements int) float64 {
+	if statements == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(statements)
+}
+
+// before reports whether line:col a comes before line:col b.
+func before(aLine, aCol, bLine, bCol int) bool {
+	return aLine < bLine || aLine == bLine && aCol < bCol
+}
@@ -0,0 +1,149 @@
+package coverage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/repocontext"
+)
+
+const cartSource = `package cart
+
+type Cart struct{ n int }
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func (c *Cart) Inc() {
+	if c.n > 10 {
+		return
+	}
+	c.n++
+}
+`
+
+func loadRepo(t *testing.T) *repocontext.Repo {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":       "module example.com/shop\n\ngo 1.21\n",
+		"cart/cart.go": cartSource,
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo, err := repocontext.Analyze(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// profile returns the blocks of cart.go with the given counts for Add and
+// the three blocks of Inc.
+func profile(add, incIf, incReturn, incRest int) []agent.CoverBlock {
+	const file = "example.com/shop/cart/cart.go"
+	return []agent.CoverBlock{
+		{File: file, StartLine: 5, StartCol: 24, EndLine: 7, EndCol: 2, Statements: 1, Count: add},
+		{File: file, StartLine: 9, StartCol: 22, EndLine: 10, EndCol: 14, Statements: 1, Count: incIf},
+		{File: file, StartLine: 10, StartCol: 14, EndLine: 12, EndCol: 3, Statements: 1, Count: incReturn},
+		{File: file, StartLine: 13, StartCol: 2, EndLine: 14, EndCol: 2, Statements: 1, Count: incRest},
+		{File: "golang.org/x/text/width/width.go", StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, Statements: 3, Count: 1},
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	funcs, err := Functions(loadRepo(t), profile(1, 1, 0, 1))
+	if err != nil {
+		t.Fatalf("Functions failed: %v", err)
+	}
+	want := []Function{
+		{File: "cart/cart.go", Name: "Add", Line: 5, Statements: 1, Covered: 1},
+		{File: "cart/cart.go", Name: "Cart.Inc", Line: 9, Statements: 3, Covered: 2},
+	}
+	if len(funcs) != len(want) {
+		t.Fatalf("expected %d functions, got %+v", len(want), funcs)
+	}
+	for i := range want {
+		if funcs[i] != want[i] {
+			t.Errorf("function %d: expected %+v, got %+v", i, want[i], funcs[i])
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	repo := loadRepo(t)
+	before, err := Functions(repo, profile(1, 0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := Functions(repo, profile(1, 1, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := Compare(before, after)
+	if len(changes) != 1 || changes[0].Name != "Cart.Inc" || changes[0].Gain() != 3 {
+		t.Fatalf("expected Cart.Inc to gain 3 statements, got %+v", changes)
+	}
+
+	report := Report(before, after)
+	for _, want := range []string{
+		"total: 25.0% -> 100.0% of statements",
+		"cart/cart.go:9: Cart.Inc 0.0% -> 100.0% (+3 statements)",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "Add") {
+		t.Errorf("expected unchanged functions to be left out, got:\n%s", report)
+	}
+
+	if report := Report(after, after); !strings.HasSuffix(report, ", no change") {
+		t.Errorf("expected no change, got %q", report)
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	repo := loadRepo(t)
+	a, err := NewAnalyzer(repo, "cart/cart.go", []string{"Cart.Inc"})
+	if err != nil {
+		t.Fatalf("NewAnalyzer failed: %v", err)
+	}
+
+	status, err := a.Analyze(profile(0, 1, 0, 1))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if status.Covered != 2 || status.Statements != 3 {
+		t.Errorf("expected only Cart.Inc to be counted, got %d/%d", status.Covered, status.Statements)
+	}
+	want := agent.CoverageGap{File: "cart/cart.go", Function: "Cart.Inc", StartLine: 10, EndLine: 12, Source: "\tif c.n > 10 {\n\t\treturn\n\t}"}
+	if len(status.Gaps) != 1 || status.Gaps[0] != want {
+		t.Errorf("expected gap %+v, got %+v", want, status.Gaps)
+	}
+
+	// Adjacent uncovered blocks form one gap.
+	if status, _ = a.Analyze(profile(0, 1, 0, 0)); len(status.Gaps) != 1 || status.Gaps[0].EndLine != 14 {
+		t.Errorf("expected one gap through line 14, got %+v", status.Gaps)
+	}
+
+	// A profile of other files is not full coverage
+	if _, err := a.Analyze(profile(0, 0, 0, 0)[4:]); err == nil {
+		t.Error("expected error for a profile without blocks of cart.go")
+	}
+
+	if _, err := NewAnalyzer(repo, "docs/readme.go", nil); err == nil {
+		t.Error("expected error for a file outside the packages")
+	}
+}
@@ -0,0 +1,210 @@
+// Package gitdiff computes the files and line ranges changed relative to a
+// git base ref, using the local git binary only.
+package gitdiff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Status is how a file changed relative to the base.
+type Status string
+
+const (
+	Added    Status = "added"
+	Modified Status = "modified"
+	Deleted  Status = "deleted"
+	Renamed  Status = "renamed"
+)
+
+// FileChange is a file that differs from the base.
+type FileChange struct {
+	// Path is the slash-separated path relative to the repo root; for
+	// deleted files it is the old path
+	Path    string
+	OldPath string
+	Status  Status
+
+	// Hunks are the changed line ranges of the new file. Added files have a
+	// single hunk covering the whole file.
+	Hunks []Hunk
+}
+
+// Hunk is an inclusive range of changed lines in the new file. Pure
+// deletions are recorded as the line before the deleted lines.
+type Hunk struct {
+	Start int
+	End   int
+}
+
+// Overlaps reports whether any hunk intersects the inclusive line range.
+func (f FileChange) Overlaps(start, end int) bool {
+	for _, h := range f.Hunks {
+		if h.Start <= end && start <= h.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes returns the files changed between the merge base of base and
+// HEAD and the working tree, i.e. the commits of a branch together with any
+// uncommitted and untracked (but not ignored) files.
+func Changes(ctx context.Context, repo, base string) ([]FileChange, error) {
+	mergeBase, err := git(ctx, repo, "merge-base", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	// The prefixes are set explicitly since diff.noprefix and
+	// diff.mnemonicPrefix would change them
+	out, err := git(ctx, repo, "diff", "--no-color", "--no-ext-diff", "--unified=0", "-M",
+		"--src-prefix=a/", "--dst-prefix=b/", strings.TrimSpace(mergeBase), "--")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := ParseDiff(out)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git(ctx, repo, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(untracked, "\x00") {
+		if path != "" {
+			changes = append(changes, FileChange{Path: path, Status: Added, Hunks: []Hunk{{Start: 1, End: maxLine}}})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// maxLine stands for "to the end of the file".
+const maxLine = int(^uint(0) >> 1)
+
+// ParseDiff parses the output of "git diff --unified=0" with the default
+// a/ and b/ path prefixes.
+func ParseDiff(diff string) ([]FileChange, error) {
+	var (
+		changes []FileChange
+		cur     *FileChange
+	)
+	flush := func() {
+		if cur != nil {
+			if cur.Status == Added {
+				cur.Hunks = []Hunk{{Start: 1, End: maxLine}}
+			}
+			changes = append(changes, *cur)
+			cur = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			cur = &FileChange{Status: Modified}
+			if a, b, ok := splitDiffHeader(strings.TrimPrefix(line, "diff --git ")); ok {
+				cur.OldPath, cur.Path = a, b
+			}
+		case cur == nil:
+			continue
+		case strings.HasPrefix(line, "new file mode"):
+			cur.Status = Added
+		case strings.HasPrefix(line, "deleted file mode"):
+			cur.Status = Deleted
+		case strings.HasPrefix(line, "rename from "):
+			cur.Status = Renamed
+			cur.OldPath = unquotePath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			cur.Path = unquotePath(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "--- "):
+			if p := strings.TrimPrefix(line, "--- "); p != "/dev/null" {
+				cur.OldPath = strings.TrimPrefix(unquotePath(p), "a/")
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if p := strings.TrimPrefix(line, "+++ "); p != "/dev/null" {
+				cur.Path = strings.TrimPrefix(unquotePath(p), "b/")
+			} else {
+				cur.Path = cur.OldPath
+			}
+		case strings.HasPrefix(line, "@@ "):
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			cur.Hunks = append(cur.Hunks, h)
+		}
+	}
+	flush()
+	return changes, scanner.Err()
+}
+
+// parseHunkHeader reads the new-file range of "@@ -a,b +c,d @@".
+func parseHunkHeader(line string) (Hunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+	start, count := fields[2][1:], "1"
+	if s, c, ok := strings.Cut(start, ","); ok {
+		start, count = s, c
+	}
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+	if n == 0 {
+		return Hunk{Start: s, End: s}, nil
+	}
+	return Hunk{Start: s, End: s + n - 1}, nil
+}
+
+// splitDiffHeader splits "a/old b/new" for paths without spaces; other
+// cases are resolved by the ---/+++ and rename lines.
+func splitDiffHeader(s string) (string, string, bool) {
+	parts := strings.Split(s, " ")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "a/") || !strings.HasPrefix(parts[1], "b/") {
+		return "", "", false
+	}
+	return parts[0][2:], parts[1][2:], true
+}
+
+// unquotePath decodes paths that git quotes because they contain special
+// characters.
+func unquotePath(p string) string {
+	if strings.HasPrefix(p, `"`) {
+		if u, err := strconv.Unquote(p); err == nil {
+			return u
+		}
+	}
+	return p
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,131 @@
+package gitdiff
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/cart/cart.go b/cart/cart.go
+index 1111111..2222222 100644
+--- a/cart/cart.go
++++ b/cart/cart.go
+@@ -10,2 +10,3 @@ func Add() {
+-	old
++	new
++	more
+@@ -30 +31,0 @@ func Total() {
+-	removed
+diff --git a/new.go b/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,5 @@
++package x
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package x
+diff --git a/old name.go b/new name.go
+similarity index 100%
+rename from old name.go
+rename to new name.go
+`
+	got, err := ParseDiff(diff)
+	if err != nil {
+		t.Fatalf("ParseDiff failed: %v", err)
+	}
+	want := []FileChange{
+		{Path: "cart/cart.go", OldPath: "cart/cart.go", Status: Modified, Hunks: []Hunk{{10, 12}, {31, 31}}},
+		{Path: "new.go", OldPath: "new.go", Status: Added, Hunks: []Hunk{{1, maxLine}}},
+		{Path: "gone.go", OldPath: "gone.go", Status: Deleted, Hunks: []Hunk{{0, 0}}},
+		{Path: "new name.go", OldPath: "old name.go", Status: Renamed},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected changes:\n got %+v\nwant %+v", got, want)
+	}
+
+	if !got[0].Overlaps(5, 10) || !got[0].Overlaps(31, 40) || got[0].Overlaps(13, 30) {
+		t.Errorf("unexpected overlap results for hunks %+v", got[0].Hunks)
+	}
+}
+
+func TestParseDiff_MalformedHunk(t *testing.T) {
+	if _, err := ParseDiff("diff --git a/x b/x\n@@ -1 +a,b @@\n"); err == nil {
+		t.Error("expected error for malformed hunk header")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q", "-b", "main")
+	write("a.go", "package a\n\nfunc A() {}\n\nfunc B() {}\n")
+	write(".gitignore", "*.log\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "base")
+
+	run("checkout", "-q", "-b", "feature")
+	write("a.go", "package a\n\nfunc A() {}\n\nfunc B() { println() }\n")
+	run("commit", "-q", "-am", "change B")
+	write("b.go", "package a\n")
+	write("debug.log", "ignored")
+
+	changes, err := Changes(context.Background(), dir, "main")
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	want := []FileChange{
+		{Path: "a.go", OldPath: "a.go", Status: Modified, Hunks: []Hunk{{5, 5}}},
+		{Path: "b.go", Status: Added, Hunks: []Hunk{{1, maxLine}}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("unexpected changes:\n got %+v\nwant %+v", changes, want)
+	}
+
+	// Prefix settings of the user must not leak into the paths
+	for _, key := range []string{"diff.noprefix", "diff.mnemonicPrefix"} {
+		run("config", key, "true")
+		changes, err := Changes(context.Background(), dir, "main")
+		if err != nil {
+			t.Fatalf("Changes with %s failed: %v", key, err)
+		}
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("unexpected changes with %s:\n got %+v\nwant %+v", key, changes, want)
+		}
+		run("config", "--unset", key)
+	}
+
+	if _, err := Changes(context.Background(), dir, "no-such-ref"); err == nil {
+		t.Error("expected error for unknown base ref")
+	}
+}
@@ -0,0 +1,223 @@
+// Package goanalysis extracts focused context about Go functions for test
+// generation: signatures, doc comments, referenced types, callers and
+// existing tests.
+package goanalysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"localsprite/internal/goast"
+	"localsprite/internal/repocontext"
+)
+
+// Package is a type-checked Go package together with its tests.
+type Package struct {
+	// Dir is the slash-separated package directory relative to the repo root
+	Dir        string
+	ImportPath string
+	Name       string
+
+	Fset  *token.FileSet
+	Types *types.Package
+
+	// TypeErrors are reported by the type checker, e.g. for dependencies
+	// that are not available offline. Analysis continues on a best-effort
+	// basis.
+	TypeErrors []error
+
+	repo  *repocontext.Repo
+	files []*file
+	refs  map[types.Object][]ref
+}
+
+type file struct {
+	name   string
+	src    []byte
+	ast    *ast.File
+	info   *types.Info
+	isTest bool
+}
+
+// ref is a use of an object inside a function.
+type ref struct {
+	file   *file
+	pos    token.Pos
+	caller string
+	isCall bool
+}
+
+// Load parses and type-checks the package in dir (relative to the repo root)
+// including its in-package and external tests. Build constraints are
+// evaluated for the host platform.
+func Load(repo *repocontext.Repo, dir string) (*Package, error) {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	abs := filepath.Join(repo.Root, filepath.FromSlash(dir))
+
+	bp, err := build.Default.ImportDir(abs, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package %s: %w", dir, err)
+	}
+
+	p := &Package{
+		Dir:        dir,
+		ImportPath: dir,
+		Name:       bp.Name,
+		Fset:       token.NewFileSet(),
+		repo:       repo,
+		refs:       map[types.Object][]ref{},
+	}
+	if rp := repo.PackageDir(dir); rp != nil {
+		p.ImportPath = rp.ImportPath
+	}
+
+	var pkgFiles, xtestFiles []*file
+	for _, names := range []struct {
+		list   []string
+		isTest bool
+		xtest  bool
+	}{
+		{bp.GoFiles, false, false},
+		{bp.TestGoFiles, true, false},
+		{bp.XTestGoFiles, true, true},
+	} {
+		for _, name := range names.list {
+			f, err := p.parse(abs, name, names.isTest)
+			if err != nil {
+				return nil, err
+			}
+			if names.xtest {
+				xtestFiles = append(xtestFiles, f)
+			} else {
+				pkgFiles = append(pkgFiles, f)
+			}
+		}
+	}
+
+	src := importer.ForCompiler(p.Fset, "source", nil).(types.ImporterFrom)
+	p.Types = p.check(p.ImportPath, pkgFiles, src)
+	if len(xtestFiles) > 0 {
+		p.check(p.ImportPath+"_test", xtestFiles, &selfImporter{p.ImportPath, p.Types, src})
+	}
+
+	p.files = append(pkgFiles, xtestFiles...)
+	for _, f := range p.files {
+		p.index(f)
+	}
+	return p, nil
+}
+
+func (p *Package) parse(dir, name string, isTest bool) (*file, error) {
+	path := filepath.Join(dir, name)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Absolute file names let the source importer resolve imports relative
+	// to the file's module.
+	f, err := parser.ParseFile(p.Fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return &file{name: name, src: src, ast: f, isTest: isTest}, nil
+}
+
+// check type-checks files, recording errors instead of stopping at them.
+func (p *Package) check(path string, files []*file, imp types.Importer) *types.Package {
+	info := &types.Info{
+		Defs: map[*ast.Ident]types.Object{},
+		Uses: map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{
+		Importer: imp,
+		Error:    func(err error) { p.TypeErrors = append(p.TypeErrors, err) },
+	}
+	asts := make([]*ast.File, len(files))
+	for i, f := range files {
+		asts[i] = f.ast
+		f.info = info
+	}
+	pkg, _ := conf.Check(path, p.Fset, asts, info)
+	return pkg
+}
+
+// index records every use of an object inside a function declaration.
+func (p *Package) index(f *file) {
+	for _, decl := range f.ast.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		caller := goast.FuncName(fn)
+		calls := map[*ast.Ident]bool{}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if id := calleeIdent(n.Fun); id != nil {
+					calls[id] = true
+				}
+			case *ast.Ident:
+				if obj := f.info.Uses[n]; obj != nil {
+					p.refs[obj] = append(p.refs[obj], ref{file: f, pos: n.Pos(), caller: caller, isCall: calls[n]})
+				}
+			}
+			return true
+		})
+	}
+}
+
+// selfImporter resolves the package under test to its already checked
+// types so that external tests refer to the same objects.
+type selfImporter struct {
+	path string
+	pkg  *types.Package
+	next types.ImporterFrom
+}
+
+func (s *selfImporter) Import(path string) (*types.Package, error) {
+	return s.ImportFrom(path, "", 0)
+}
+
+func (s *selfImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if path == s.path && s.pkg != nil {
+		return s.pkg, nil
+	}
+	return s.next.ImportFrom(path, dir, mode)
+}
+
+// calleeIdent returns the identifier naming the called function, if any.
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	for {
+		switch e := fun.(type) {
+		case *ast.ParenExpr:
+			fun = e.X
+		case *ast.IndexExpr:
+			fun = e.X
+		case *ast.IndexListExpr:
+			fun = e.X
+		case *ast.SelectorExpr:
+			return e.Sel
+		case *ast.Ident:
+			return e
+		default:
+			return nil
+		}
+	}
+}
+
+func isTestFunc(name string) bool {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package goanalysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"localsprite/internal/repocontext"
+)
+
+func loadTestPackage(t *testing.T) *Package {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shop\n\ngo 1.24\n",
+		"cart/cart.go": `package cart
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFull is returned when the cart has no room left.
+var ErrFull = errors.New("cart full")
+
+// Item is a line of a cart.
+type Item struct {
+	SKU   string
+	Cents int64
+}
+
+// Cart holds the items of an order.
+type Cart struct {
+	Items   []Item
+	Updated time.Time
+}
+
+// Add appends an item unless the cart is full.
+func (c *Cart) Add(it Item) error {
+	if len(c.Items) >= 10 {
+		return ErrFull
+	}
+	c.Items = append(c.Items, it)
+	c.touch()
+	return nil
+}
+
+func (c *Cart) touch() { c.Updated = time.Now() }
+
+// Total sums the cart.
+func Total(c *Cart) int64 {
+	var sum int64
+	for _, it := range c.Items {
+		sum += it.Cents
+	}
+	return sum
+}
+
+func Checkout(c *Cart, at time.Time) int64 { return Total(c) }
+`,
+		"cart/cart_test.go": `package cart
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	var c Cart
+	if err := c.Add(Item{SKU: "a"}); err != nil {
+		t.Fatal(err)
+	}
+}
+`,
+		"cart/checkout_test.go": `package cart_test
+
+import (
+	"testing"
+	"time"
+
+	"example.com/shop/cart"
+)
+
+func TestCheckout(t *testing.T) {
+	cart.Checkout(&cart.Cart{}, time.Now())
+}
+`,
+		"report/report.go": `package report
+
+import c "example.com/shop/cart"
+
+func Summary(x *c.Cart) int64 { return c.Total(x) }
+`,
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := repocontext.Analyze(root)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	p, err := Load(repo, "cart")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(p.TypeErrors) > 0 {
+		t.Fatalf("unexpected type errors: %v", p.TypeErrors)
+	}
+	return p
+}
+
+func TestTarget_Method(t *testing.T) {
+	p := loadTestPackage(t)
+
+	target, err := p.Target("Cart.Add")
+	if err != nil {
+		t.Fatalf("Target failed: %v", err)
+	}
+	if target.Signature != "func (c *Cart) Add(it Item) error" {
+		t.Errorf("unexpected signature %q", target.Signature)
+	}
+	if target.Doc != "Add appends an item unless the cart is full." || target.File != "cart.go" {
+		t.Errorf("unexpected doc or file: %q %q", target.Doc, target.File)
+	}
+	if !strings.HasPrefix(target.Source, "func (c *Cart) Add") || !strings.HasSuffix(target.Source, "}") {
+		t.Errorf("unexpected source %q", target.Source)
+	}
+	if len(target.Types) != 2 || !strings.HasPrefix(target.Types[0], "type Cart struct") || !strings.HasPrefix(target.Types[1], "type Item struct") {
+		t.Errorf("expected Cart and Item declarations, got %q", target.Types)
+	}
+	if len(target.Tests) != 1 || target.Tests[0] != "TestAdd" {
+		t.Errorf("expected TestAdd, got %v", target.Tests)
+	}
+}
+
+func TestTarget_Callers(t *testing.T) {
+	p := loadTestPackage(t)
+
+	target, err := p.Target("Total")
+	if err != nil {
+		t.Fatalf("Target failed: %v", err)
+	}
+	var callers []string
+	for _, c := range target.Callers {
+		callers = append(callers, c.Name)
+	}
+	if strings.Join(callers, ",") != "Checkout,example.com/shop/report.Summary" {
+		t.Errorf("unexpected callers %v", callers)
+	}
+	if target.Tested() {
+		t.Errorf("expected Total to be untested, got %v", target.Tests)
+	}
+
+	checkout, err := p.Target("Checkout")
+	if err != nil {
+		t.Fatalf("Target failed: %v", err)
+	}
+	if len(checkout.External) != 1 || checkout.External[0] != "time.Time" {
+		t.Errorf("expected time.Time as external type, got %v", checkout.External)
+	}
+
+	touch, err := p.Target("Cart.touch")
+	if err != nil {
+		t.Fatalf("Target failed: %v", err)
+	}
+	if len(touch.Callers) != 1 || touch.Callers[0].Name != "Cart.Add" {
+		t.Errorf("expected Cart.Add to call touch, got %+v", touch.Callers)
+	}
+}
+
+func TestUntested(t *testing.T) {
+	p := loadTestPackage(t)
+
+	// Checkout is only referenced by the external test package.
+	if got := strings.Join(p.Untested("cart.go"), ","); got != "Total" {
+		t.Errorf("expected only Total to be untested, got %q", got)
+	}
+	if _, err := p.Target("Missing"); err == nil {
+		t.Error("expected error for unknown function")
+	}
+}
+
+func TestRender(t *testing.T) {
+	p := loadTestPackage(t)
+	target, err := p.Target("Total")
+	if err != nil {
+		t.Fatalf("Target failed: %v", err)
+	}
+
+	out := p.Render([]*Target{target})
+	for _, want := range []string{
+		`package cart // import "example.com/shop/cart"`,
+		"Exported functions without tests: Total",
+		"### Total (cart.go:",
+		"Callers: Checkout (cart.go:",
+		"Existing tests: none",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestFunctionsIn(t *testing.T) {
+	p := loadTestPackage(t)
+
+	// Line 23 is the doc comment of Cart.Add; 33 is the body of touch.
+	lines := map[int]bool{23: true, 33: true}
+	changed := func(start, end int) bool {
+		for l := range lines {
+			if start <= l && l <= end {
+				return true
+			}
+		}
+		return false
+	}
+	if got := strings.Join(p.FunctionsIn("cart.go", changed), ","); got != "Cart.Add,Cart.touch" {
+		t.Errorf("unexpected changed functions %q", got)
+	}
+}
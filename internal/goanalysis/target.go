@@ -0,0 +1,406 @@
+package goanalysis
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"localsprite/internal/goast"
+)
+
+// Target is a function or method selected for test generation.
+type Target struct {
+	// Name is "Func" or "Type.Method"
+	Name      string
+	File      string
+	Line      int
+	Signature string
+	Doc       string
+	Source    string
+
+	// Types are the declarations of package-local types referenced by the
+	// function; External lists types from other packages as "path.Name".
+	Types    []string
+	External []string
+
+	Callers []Caller
+
+	// Tests are the test functions that reference the target
+	Tests []string
+}
+
+// Caller is a function that calls a target.
+type Caller struct {
+	// Name is "Func" or "Type.Method", qualified by the import path for
+	// callers in other packages
+	Name string
+	File string
+	Line int
+}
+
+// Tested reports whether any test references the target.
+func (t *Target) Tested() bool {
+	return len(t.Tests) > 0
+}
+
+// Functions returns the names of the functions and methods declared in the
+// non-test file (relative to the package directory), or in the whole
+// package if file is empty.
+func (p *Package) Functions(file string, exportedOnly bool) []string {
+	var names []string
+	for _, f := range p.files {
+		if f.isTest || (file != "" && f.name != file) {
+			continue
+		}
+		for _, decl := range f.ast.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || (exportedOnly && !isExported(fn)) {
+				continue
+			}
+			names = append(names, goast.FuncName(fn))
+		}
+	}
+	return names
+}
+
+// FunctionsIn returns the functions and methods of the non-test file whose
+// declaration, including its doc comment, overlaps a line range for which
+// changed reports true.
+func (p *Package) FunctionsIn(file string, changed func(start, end int) bool) []string {
+	var names []string
+	for _, f := range p.files {
+		if f.isTest || f.name != file {
+			continue
+		}
+		for _, decl := range f.ast.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			start := fn.Pos()
+			if fn.Doc != nil {
+				start = fn.Doc.Pos()
+			}
+			if changed(p.Fset.Position(start).Line, p.Fset.Position(fn.End()).Line) {
+				names = append(names, goast.FuncName(fn))
+			}
+		}
+	}
+	return names
+}
+
+// Untested returns the exported functions and methods of the file (or the
+// whole package if file is empty) that no test references.
+func (p *Package) Untested(file string) []string {
+	var names []string
+	for _, name := range p.Functions(file, true) {
+		fn, _ := p.lookup(name)
+		if len(p.testsOf(p.object(fn))) == 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Target extracts the function or method called name ("Func" or
+// "Type.Method").
+func (p *Package) Target(name string) (*Target, error) {
+	fn, f := p.lookup(name)
+	if fn == nil {
+		return nil, fmt.Errorf("function %s not found in package %s", name, p.ImportPath)
+	}
+
+	sig := *fn
+	sig.Doc, sig.Body = nil, nil
+	t := &Target{
+		Name:      name,
+		File:      f.name,
+		Line:      p.Fset.Position(fn.Pos()).Line,
+		Signature: nodeString(p.Fset, &sig),
+		Source:    string(f.src[p.Fset.Position(fn.Pos()).Offset:p.Fset.Position(fn.End()).Offset]),
+	}
+	if fn.Doc != nil {
+		t.Doc = strings.TrimSpace(fn.Doc.Text())
+	}
+
+	obj := p.object(fn)
+	t.Types, t.External = p.referencedTypes(fn, f)
+	t.Callers = append(p.callersOf(obj), p.externalCallers(fn)...)
+	t.Tests = p.testsOf(obj)
+	return t, nil
+}
+
+func (p *Package) lookup(name string) (*ast.FuncDecl, *file) {
+	for _, f := range p.files {
+		if f.isTest {
+			continue
+		}
+		for _, decl := range f.ast.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && goast.FuncName(fn) == name {
+				return fn, f
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (p *Package) object(fn *ast.FuncDecl) types.Object {
+	if fn == nil {
+		return nil
+	}
+	for _, f := range p.files {
+		if obj := f.info.Defs[fn.Name]; obj != nil {
+			return obj
+		}
+	}
+	return nil
+}
+
+// referencedTypes returns the source of package-local type declarations and
+// the qualified names of other types named in the function.
+func (p *Package) referencedTypes(fn *ast.FuncDecl, f *file) (local, external []string) {
+	seen := map[types.Object]bool{}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		tn, ok := f.info.Uses[id].(*types.TypeName)
+		if !ok || tn.Pkg() == nil || seen[tn] {
+			return true
+		}
+		seen[tn] = true
+
+		if tn.Pkg() == p.Types {
+			if decl := p.typeDecl(tn); decl != "" {
+				local = append(local, decl)
+			}
+		} else {
+			external = append(external, tn.Pkg().Path()+"."+tn.Name())
+		}
+		return true
+	})
+	sort.Strings(external)
+	return local, external
+}
+
+// typeDecl returns the source of the declaration of a package-local type.
+func (p *Package) typeDecl(tn *types.TypeName) string {
+	for _, f := range p.files {
+		if f.isTest {
+			continue
+		}
+		for _, decl := range f.ast.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if f.info.Defs[ts.Name] != tn {
+					continue
+				}
+				var buf bytes.Buffer
+				buf.WriteString("type ")
+				if err := printer.Fprint(&buf, p.Fset, ts); err != nil {
+					return ""
+				}
+				return buf.String()
+			}
+		}
+	}
+	return ""
+}
+
+// callersOf returns the package's non-test functions that call obj.
+func (p *Package) callersOf(obj types.Object) []Caller {
+	var callers []Caller
+	seen := map[string]bool{}
+	for _, r := range p.refs[obj] {
+		if r.file.isTest || !r.isCall || seen[r.caller] {
+			continue
+		}
+		seen[r.caller] = true
+		callers = append(callers, Caller{
+			Name: r.caller,
+			File: r.file.name,
+			Line: p.Fset.Position(r.pos).Line,
+		})
+	}
+	return callers
+}
+
+// testsOf returns the test functions that reference obj.
+func (p *Package) testsOf(obj types.Object) []string {
+	if obj == nil {
+		return nil
+	}
+	var tests []string
+	seen := map[string]bool{}
+	for _, r := range p.refs[obj] {
+		if r.file.isTest && isTestFunc(r.caller) && !seen[r.caller] {
+			seen[r.caller] = true
+			tests = append(tests, r.caller)
+		}
+	}
+	return tests
+}
+
+// externalCallers finds calls to a package-level function from the other
+// packages of the repository that import this one. It matches
+// "pkg.Func(...)" syntactically, so calls through method values or
+// interfaces are not found.
+func (p *Package) externalCallers(fn *ast.FuncDecl) []Caller {
+	if fn.Recv != nil || !fn.Name.IsExported() || p.repo == nil {
+		return nil
+	}
+
+	var callers []Caller
+	for _, rp := range p.repo.Packages {
+		if rp.Dir == p.Dir || !contains(rp.Imports, p.ImportPath) {
+			continue
+		}
+		for _, name := range rp.Files {
+			rel := path.Join(rp.Dir, name)
+			src, err := os.ReadFile(filepath.Join(p.repo.Root, filepath.FromSlash(rel)))
+			if err != nil {
+				continue
+			}
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, rel, src, parser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			local := importName(f, p.ImportPath, p.Name)
+			if local == "" {
+				continue
+			}
+			for _, decl := range f.Decls {
+				caller, ok := decl.(*ast.FuncDecl)
+				if !ok || caller.Body == nil {
+					continue
+				}
+				ast.Inspect(caller.Body, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					sel, ok := call.Fun.(*ast.SelectorExpr)
+					if !ok || sel.Sel.Name != fn.Name.Name {
+						return true
+					}
+					if x, ok := sel.X.(*ast.Ident); ok && x.Name == local {
+						callers = append(callers, Caller{
+							Name: rp.ImportPath + "." + goast.FuncName(caller),
+							File: rel,
+							Line: fset.Position(call.Pos()).Line,
+						})
+						return false
+					}
+					return true
+				})
+			}
+		}
+	}
+	return callers
+}
+
+// importName returns the name under which f imports path, or "" if it does
+// not (or imports it for side effects only).
+func importName(f *ast.File, path, pkgName string) string {
+	for _, imp := range f.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != path {
+			continue
+		}
+		if imp.Name == nil {
+			return pkgName
+		}
+		if imp.Name.Name == "_" || imp.Name.Name == "." {
+			return ""
+		}
+		return imp.Name.Name
+	}
+	return ""
+}
+
+// String renders the target as Markdown for planner and coder prompts.
+func (t *Target) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s (%s:%d)\n", t.Name, t.File, t.Line)
+	if t.Doc != "" {
+		fmt.Fprintf(&b, "%s\n", t.Doc)
+	}
+	fmt.Fprintf(&b, "```go\n%s\n```\n", t.Source)
+	if len(t.Types) > 0 {
+		fmt.Fprintf(&b, "Referenced types:\n```go\n%s\n```\n", strings.Join(t.Types, "\n\n"))
+	}
+	if len(t.External) > 0 {
+		fmt.Fprintf(&b, "External types: %s\n", strings.Join(t.External, ", "))
+	}
+	if len(t.Callers) > 0 {
+		var callers []string
+		for _, c := range t.Callers {
+			callers = append(callers, fmt.Sprintf("%s (%s:%d)", c.Name, c.File, c.Line))
+		}
+		fmt.Fprintf(&b, "Callers: %s\n", strings.Join(callers, ", "))
+	}
+	if len(t.Tests) > 0 {
+		fmt.Fprintf(&b, "Existing tests: %s\n", strings.Join(t.Tests, ", "))
+	} else {
+		b.WriteString("Existing tests: none\n")
+	}
+	return b.String()
+}
+
+// Render describes the package and targets for planner and coder prompts,
+// in place of the full source of the target file.
+func (p *Package) Render(targets []*Target) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s // import %q\n\n", p.Name, p.ImportPath)
+	if untested := p.Untested(""); len(untested) > 0 {
+		fmt.Fprintf(&b, "Exported functions without tests: %s\n\n", strings.Join(untested, ", "))
+	}
+	for _, t := range targets {
+		b.WriteString(t.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func isExported(fn *ast.FuncDecl) bool {
+	if !fn.Name.IsExported() {
+		return false
+	}
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		return ast.IsExported(goast.ReceiverType(fn.Recv.List[0].Type))
+	}
+	return true
+}
+
+// nodeString prints node on a single line.
+func nodeString(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+// Package goast names Go declarations the way LocalSprite's packages look
+// them up in each other's results.
+package goast
+
+import "go/ast"
+
+// FuncName returns "Name" for functions and "Type.Name" for methods, the
+// form in which functions are looked up across packages.
+func FuncName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	if recv := ReceiverType(fn.Recv.List[0].Type); recv != "" {
+		return recv + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// ReceiverType returns the base type name of a method receiver, without
+// pointers or type parameters.
+func ReceiverType(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
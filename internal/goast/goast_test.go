@@ -0,0 +1,32 @@
+package goast
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestFuncName(t *testing.T) {
+	src := `package p
+
+func F() {}
+func (T) A() {}
+func (t *T) B() {}
+func (l *List[E]) C() {}
+func (m Map[K, V]) D() {}
+func ((*T)) E() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, decl := range f.Decls {
+		got = append(got, FuncName(decl.(*ast.FuncDecl)))
+	}
+	if want := "F T.A T.B List.C Map.D T.E"; strings.Join(got, " ") != want {
+		t.Errorf("FuncName = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,275 @@
+// Package govalidate checks generated Go code before it is executed: syntax
+// errors are reported, imports are fixed and the code is formatted, and
+// go vet runs against the generated files in their packages. It implements
+// agent.Validator, so that code that cannot compile never costs a container
+// start.
+package govalidate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/repocontext"
+)
+
+// Validator validates generated Go files.
+type Validator struct {
+	// TestFile is the name given to unnamed files, as by the executor's
+	// test_file_pattern (default: generated_test.go)
+	TestFile string
+
+	// Vet runs go vet with the local Go toolchain when the workspace has a
+	// source directory. It is skipped when go is not installed.
+	Vet bool
+
+	// packages caches the repository's package names by source directory
+	packages map[string]map[string]string
+}
+
+// New returns a Validator that also runs go vet.
+func New(testFile string) *Validator {
+	if testFile == "" {
+		testFile = "generated_test.go"
+	}
+	return &Validator{TestFile: testFile, Vet: true}
+}
+
+// Validate implements agent.Validator. Files that are not Go source are
+// passed through unchanged.
+func (v *Validator) Validate(ctx context.Context, ws agent.Workspace, files []agent.Artifact) ([]agent.Artifact, []agent.Diagnostic, error) {
+	local, err := v.localPackages(ws.SourceDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var diags []agent.Diagnostic
+	out := make([]agent.Artifact, len(files))
+	goFiles := map[string]string{}
+	for i, f := range files {
+		out[i] = f
+		name := v.resolve(ws, f.Path)
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+
+		content, fileDiags := fixFile(name, f.Content, local)
+		out[i].Content = content
+		diags = append(diags, fileDiags...)
+		goFiles[name] = content
+	}
+	if len(diags) > 0 || len(goFiles) == 0 || !v.Vet || ws.SourceDir == "" {
+		return out, diags, nil
+	}
+
+	vetDiags, err := vet(ctx, ws.SourceDir, goFiles)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		// The toolchain or dependencies may be missing locally; the executor
+		// will still find any real problem.
+		fmt.Printf("[Validator] Skipping go vet: %v\n", err)
+	}
+	return out, vetDiags, nil
+}
+
+// resolve returns the path an executor writes the file to.
+func (v *Validator) resolve(ws agent.Workspace, name string) string {
+	if name != "" {
+		return path.Clean(name)
+	}
+	return ws.TestFile(v.TestFile)
+}
+
+// localPackages maps the names of the repository's packages to their import
+// paths, leaving out names used by more than one package.
+func (v *Validator) localPackages(root string) (map[string]string, error) {
+	if root == "" {
+		return nil, nil
+	}
+	if pkgs, ok := v.packages[root]; ok {
+		return pkgs, nil
+	}
+
+	repo, err := repocontext.Analyze(root)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := map[string]string{}
+	dup := map[string]bool{}
+	for _, p := range repo.Packages {
+		if _, ok := pkgs[p.Name]; ok {
+			dup[p.Name] = true
+		}
+		pkgs[p.Name] = p.ImportPath
+	}
+	for name := range dup {
+		delete(pkgs, name)
+	}
+
+	if v.packages == nil {
+		v.packages = map[string]map[string]string{}
+	}
+	v.packages[root] = pkgs
+	return pkgs, nil
+}
+
+// fixFile parses, fixes the imports of and formats one file. Syntax errors
+// are returned as diagnostics with the content unchanged.
+func fixFile(name, content string, local map[string]string) (string, []agent.Diagnostic) {
+	fset := token.NewFileSet()
+	src := []byte(content)
+	f, err := parser.ParseFile(fset, name, src, parser.ParseComments|parser.AllErrors)
+	if err != nil {
+		return content, syntaxDiagnostics(name, err)
+	}
+
+	fixed, fix := fixImports(fset, f, src, local)
+	if len(fix.added) > 0 {
+		fmt.Printf("[Validator] %s: added imports %s\n", name, strings.Join(fix.added, ", "))
+	}
+	if len(fix.removed) > 0 {
+		fmt.Printf("[Validator] %s: removed unused imports %s\n", name, strings.Join(fix.removed, ", "))
+	}
+
+	formatted, err := format.Source(fixed)
+	if err != nil {
+		// Only possible if fixing the imports broke the file; keep the
+		// original rather than failing the attempt.
+		if formatted, err = format.Source(src); err != nil {
+			return content, nil
+		}
+	}
+	return string(formatted), nil
+}
+
+func syntaxDiagnostics(name string, err error) []agent.Diagnostic {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return []agent.Diagnostic{{File: name, Source: "syntax", Message: err.Error()}}
+	}
+	diags := make([]agent.Diagnostic, 0, len(list))
+	for _, e := range list {
+		diags = append(diags, agent.Diagnostic{
+			File:    name,
+			Line:    e.Pos.Line,
+			Column:  e.Pos.Column,
+			Source:  "syntax",
+			Message: e.Msg,
+		})
+	}
+	return diags
+}
+
+// vetLine matches the positions go vet and the compiler report, e.g.
+// "vet: cart/cart_test.go:12:3: undefined: Foo" or "./x_test.go:4:2: ...".
+var vetLine = regexp.MustCompile(`^(?:vet: )?(.+?\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// vet runs go vet on the packages of the generated files, which are laid
+// over the checkout with -overlay rather than written into it. Only
+// problems in the generated files are returned; an error means vet failed
+// for another reason.
+func vet(ctx context.Context, root string, files map[string]string) ([]agent.Diagnostic, error) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return nil, errors.New("go is not installed")
+	}
+
+	tmp, err := os.MkdirTemp("", "localsprite-vet-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	overlay := struct{ Replace map[string]string }{Replace: map[string]string{}}
+	dirs := map[string]bool{}
+	// Diagnostics may name either the original or the replacement file.
+	byPath := map[string]string{}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		p := filepath.Join(tmp, strconv.Itoa(i)+".go")
+		if err := os.WriteFile(p, []byte(files[name]), 0o644); err != nil {
+			return nil, err
+		}
+		orig := filepath.Join(root, filepath.FromSlash(name))
+		overlay.Replace[orig] = p
+		byPath[orig], byPath[p] = name, name
+		dirs["./"+path.Dir(name)] = true
+	}
+	data, err := json.Marshal(overlay)
+	if err != nil {
+		return nil, err
+	}
+	overlayFile := filepath.Join(tmp, "overlay.json")
+	if err := os.WriteFile(overlayFile, data, 0o644); err != nil {
+		return nil, err
+	}
+
+	args := []string{"vet", "-overlay=" + overlayFile}
+	for dir := range dirs {
+		args = append(args, dir)
+	}
+	sort.Strings(args[2:])
+
+	cmd := exec.CommandContext(ctx, goBin, args...)
+	cmd.Dir = root
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if runErr := cmd.Run(); runErr == nil {
+		return nil, nil
+	}
+
+	diags := parseVet(out.String(), root, byPath)
+	if len(diags) == 0 {
+		first, _, _ := strings.Cut(strings.TrimSpace(out.String()), "\n")
+		return nil, fmt.Errorf("go vet failed outside the generated files: %s", first)
+	}
+	return diags, nil
+}
+
+// parseVet returns the problems reported in the generated files, which
+// byPath maps from absolute file paths to generated file names. Relative
+// paths in the output are relative to root.
+func parseVet(output, root string, byPath map[string]string) []agent.Diagnostic {
+	var diags []agent.Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := vetLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		p := filepath.FromSlash(m[1])
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		name, ok := byPath[filepath.Clean(p)]
+		if !ok {
+			continue
+		}
+		d := agent.Diagnostic{File: name, Source: "vet", Message: m[4]}
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+		diags = append(diags, d)
+	}
+	return diags
+}
@@ -0,0 +1,149 @@
+package govalidate
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"localsprite/internal/agent"
+)
+
+// writeModule creates a module with a cart package and a helper package.
+func writeModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":                "module example.com/shop\n\ngo 1.21\n",
+		"cart/cart.go":          "package cart\n\nfunc Add(a, b int) int { return a + b }\n",
+		"money/money.go":        "package money\n\nfunc Cents(n int) int { return n * 100 }\n",
+		"legacy/legacy.go":      "package legacy\n\nimport \"fmt\"\n\nfunc Bad() { fmt.Printf(\"%d\", \"x\") }\n",
+		"cart/testdata/in.json": "{}",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestValidate_Syntax(t *testing.T) {
+	v := New("")
+	v.Vet = false
+	files := []agent.Artifact{{Content: "package cart\n\nfunc TestX(t *testing.T) {\n\tif {\n}\n"}}
+
+	out, diags, err := v.Validate(context.Background(), agent.Workspace{}, files)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(diags) == 0 || diags[0].Source != "syntax" || diags[0].File != "generated_test.go" || diags[0].Line != 4 {
+		t.Errorf("expected a syntax error at generated_test.go:4, got %+v", diags)
+	}
+	if out[0].Content != files[0].Content {
+		t.Errorf("expected content to be left alone, got %q", out[0].Content)
+	}
+}
+
+func TestValidate_FixesImports(t *testing.T) {
+	root := writeModule(t)
+	v := New("")
+	v.Vet = false
+	files := []agent.Artifact{
+		{Content: `package cart_test
+
+import (
+	"os"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdd(t *testing.T) {
+	if !strings.HasPrefix("ab", "a") || cart.Add(1, 2) != money.Cents(0)+3 {
+		t.Fatal("unexpected")
+	}
+	assert.True(t, true)
+}
+`},
+		{Path: "cart/testdata/out.json", Content: "{ }"},
+	}
+
+	out, diags, err := v.Validate(context.Background(), agent.Workspace{SourceDir: root, TestDir: "cart"}, files)
+	if err != nil || len(diags) > 0 {
+		t.Fatalf("Validate failed: %v %+v", err, diags)
+	}
+	want := `import (
+	"strings"
+	"testing"
+
+	"example.com/shop/cart"
+	"example.com/shop/money"
+	"github.com/stretchr/testify/assert"
+)`
+	if !strings.Contains(out[0].Content, want) {
+		t.Errorf("expected fixed imports, got:\n%s", out[0].Content)
+	}
+	if out[1].Content != "{ }" {
+		t.Errorf("expected non-Go files to pass through, got %q", out[1].Content)
+	}
+}
+
+func TestValidate_AddsImportBlock(t *testing.T) {
+	v := New("x_test.go")
+	v.Vet = false
+	files := []agent.Artifact{{Content: "package x\n\nfunc TestX(t *testing.T) { t.Log(fmt.Sprint(1)) }\n"}}
+
+	out, _, err := v.Validate(context.Background(), agent.Workspace{}, files)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	want := "package x\n\nimport (\n\t\"fmt\"\n\t\"testing\"\n)\n\nfunc TestX(t *testing.T) { t.Log(fmt.Sprint(1)) }\n"
+	if out[0].Content != want {
+		t.Errorf("unexpected content:\n%s", out[0].Content)
+	}
+}
+
+func TestValidate_Vet(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	root := writeModule(t)
+	v := New("cart_test.go")
+	ws := agent.Workspace{SourceDir: root, TestDir: "cart"}
+
+	// Problems in the existing legacy package are not reported.
+	files := []agent.Artifact{{Content: "package cart\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != Sub(3, 0) {\n\t\tt.Fatal(\"x\")\n\t}\n}\n"}}
+	_, diags, err := v.Validate(context.Background(), ws, files)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].File != "cart/cart_test.go" || diags[0].Line != 6 || !strings.Contains(diags[0].Message, "undefined: Sub") {
+		t.Errorf("expected undefined Sub in cart/cart_test.go:6, got %+v", diags)
+	}
+
+	files[0].Content = strings.Replace(files[0].Content, "Sub(3, 0)", "Add(3, 0)", 1)
+	if _, diags, err = v.Validate(context.Background(), ws, files); err != nil || len(diags) > 0 {
+		t.Errorf("expected valid code to pass vet, got %v %+v", err, diags)
+	}
+	if _, err := os.Stat(filepath.Join(root, "cart", "cart_test.go")); !os.IsNotExist(err) {
+		t.Errorf("expected vet not to write into the checkout, got %v", err)
+	}
+}
+
+func TestGuessName(t *testing.T) {
+	cases := map[string]string{
+		"github.com/stretchr/testify/assert": "assert",
+		"gopkg.in/yaml.v3":                   "yaml",
+		"github.com/jackc/pgx/v5":            "pgx",
+		"github.com/mattn/go-sqlite3":        "sqlite3",
+	}
+	for in, want := range cases {
+		if got := guessName(in); got != want {
+			t.Errorf("guessName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package govalidate
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stdPackages resolves the package names that generated tests commonly use
+// without importing them. Names shared by several packages map to the usual
+// choice (math/rand rather than crypto/rand).
+var stdPackages = map[string]string{
+	"bufio":     "bufio",
+	"bytes":     "bytes",
+	"cmp":       "cmp",
+	"context":   "context",
+	"errors":    "errors",
+	"exec":      "os/exec",
+	"filepath":  "path/filepath",
+	"fmt":       "fmt",
+	"fs":        "io/fs",
+	"fstest":    "testing/fstest",
+	"http":      "net/http",
+	"httptest":  "net/http/httptest",
+	"io":        "io",
+	"iotest":    "testing/iotest",
+	"json":      "encoding/json",
+	"log":       "log",
+	"maps":      "maps",
+	"math":      "math",
+	"net":       "net",
+	"os":        "os",
+	"path":      "path",
+	"quick":     "testing/quick",
+	"rand":      "math/rand",
+	"reflect":   "reflect",
+	"regexp":    "regexp",
+	"slices":    "slices",
+	"slog":      "log/slog",
+	"sort":      "sort",
+	"strconv":   "strconv",
+	"strings":   "strings",
+	"sync":      "sync",
+	"atomic":    "sync/atomic",
+	"testing":   "testing",
+	"time":      "time",
+	"unicode":   "unicode",
+	"url":       "net/url",
+	"utf8":      "unicode/utf8",
+	"base64":    "encoding/base64",
+	"hex":       "encoding/hex",
+	"xml":       "encoding/xml",
+	"csv":       "encoding/csv",
+	"sha256":    "crypto/sha256",
+	"tls":       "crypto/tls",
+	"template":  "text/template",
+	"tabwriter": "text/tabwriter",
+}
+
+// importFix describes how fixImports changed a file.
+type importFix struct {
+	added   []string
+	removed []string
+}
+
+type importSpec struct {
+	name string
+	path string
+}
+
+// fixImports adds imports for package names that are used but not imported
+// and removes imports that are not used, in the spirit of goimports. Only
+// imports whose package name is known (standard library packages in
+// stdPackages and the packages of the repository) are removed, since the
+// name of any other package cannot be told from its path. It returns the new
+// source, which still needs formatting, or src unchanged.
+func fixImports(fset *token.FileSet, f *ast.File, src []byte, local map[string]string) ([]byte, importFix) {
+	var fix importFix
+	for _, imp := range f.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == "C" {
+			// cgo preambles must stay attached to their import
+			return src, fix
+		}
+	}
+
+	used := usedPackages(f)
+	known := map[string]string{}
+	for name, p := range stdPackages {
+		known[p] = name
+	}
+	for name, p := range local {
+		known[p] = name
+	}
+
+	var kept []importSpec
+	imported := map[string]bool{}
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return src, importFix{}
+		}
+		spec := importSpec{path: p}
+		name, isKnown := known[p]
+		if imp.Name != nil {
+			spec.name = imp.Name.Name
+			name, isKnown = imp.Name.Name, true
+		}
+		if name == "_" || name == "." || !isKnown {
+			kept = append(kept, spec)
+			imported[guessName(p)] = true
+			continue
+		}
+		if !used[name] {
+			fix.removed = append(fix.removed, p)
+			continue
+		}
+		kept = append(kept, spec)
+		imported[name] = true
+	}
+
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if imported[name] || name == f.Name.Name {
+			continue
+		}
+		p, ok := stdPackages[name]
+		if !ok {
+			p, ok = local[name]
+		}
+		if ok {
+			kept = append(kept, importSpec{path: p})
+			fix.added = append(fix.added, p)
+		}
+	}
+
+	if len(fix.added) == 0 && len(fix.removed) == 0 {
+		return src, fix
+	}
+	return replaceImports(fset, f, src, kept), fix
+}
+
+// usedPackages returns the identifiers used as the package of a selector
+// expression that are not declared in the file, e.g. "strings" in
+// strings.Contains.
+func usedPackages(f *ast.File) map[string]bool {
+	unresolved := map[*ast.Ident]bool{}
+	for _, id := range f.Unresolved {
+		unresolved[id] = true
+	}
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && unresolved[id] {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// replaceImports swaps the file's import declarations for a single block of
+// specs, standard library packages first.
+func replaceImports(fset *token.FileSet, f *ast.File, src []byte, specs []importSpec) []byte {
+	sort.SliceStable(specs, func(i, j int) bool {
+		si, sj := isStd(specs[i].path), isStd(specs[j].path)
+		if si != sj {
+			return si
+		}
+		return specs[i].path < specs[j].path
+	})
+
+	var b strings.Builder
+	if len(specs) > 0 {
+		b.WriteString("import (\n")
+		for i, s := range specs {
+			if i > 0 && isStd(specs[i-1].path) && !isStd(s.path) {
+				b.WriteString("\n")
+			}
+			b.WriteString("\t")
+			if s.name != "" {
+				b.WriteString(s.name + " ")
+			}
+			b.WriteString(strconv.Quote(s.path) + "\n")
+		}
+		b.WriteString(")\n")
+	}
+
+	// Imports precede all other declarations, so one range covers them.
+	start, end := fset.Position(f.Name.End()).Offset, -1
+	block := "\n\n" + b.String()
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			break
+		}
+		if end < 0 {
+			start = fset.Position(gd.Pos()).Offset
+			block = b.String()
+		}
+		end = fset.Position(gd.End()).Offset
+	}
+	if end < 0 {
+		end = start
+	}
+
+	out := make([]byte, 0, len(src)+len(block))
+	out = append(out, src[:start]...)
+	out = append(out, block...)
+	return append(out, src[end:]...)
+}
+
+// isStd reports whether an import path belongs to the standard library,
+// whose paths have no dot in their first element.
+func isStd(p string) bool {
+	first, _, _ := strings.Cut(p, "/")
+	return !strings.Contains(first, ".")
+}
+
+// guessName approximates the package name of an import path: its last
+// element without a major version or "go-" prefix.
+func guessName(p string) string {
+	name := path.Base(p)
+	if strings.HasPrefix(name, "v") && path.Dir(p) != "." {
+		if _, err := strconv.Atoi(name[1:]); err == nil {
+			name = path.Base(path.Dir(p))
+		}
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "")
+}
@@ -0,0 +1,162 @@
+// Package mutation generates mutants of Go source (flipped conditionals,
+// swapped operators, dropped returns) and tests generated tests against
+// them. Each mutant is laid over the project with go test -overlay inside
+// the executor's container, so the read-only project source is never
+// modified. It implements agent.MutationTester.
+package mutation
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/goast"
+)
+
+// DefaultMaxMutants bounds how many mutants are tested per target, since
+// each one rebuilds and tests the package.
+const DefaultMaxMutants = 30
+
+// Mutant is a change to a file and the source it produces.
+type Mutant struct {
+	agent.Mutant
+
+	// Content is the whole mutated file
+	Content []byte
+}
+
+// flips are the operator replacements. Comparisons and logical operators
+// are negated, arithmetic operators swapped for their counterparts.
+var flips = map[token.Token]token.Token{
+	token.EQL:  token.NEQ,
+	token.NEQ:  token.EQL,
+	token.LSS:  token.GEQ,
+	token.GEQ:  token.LSS,
+	token.GTR:  token.LEQ,
+	token.LEQ:  token.GTR,
+	token.LAND: token.LOR,
+	token.LOR:  token.LAND,
+	token.ADD:  token.SUB,
+	token.SUB:  token.ADD,
+	token.MUL:  token.QUO,
+	token.QUO:  token.MUL,
+	token.REM:  token.MUL,
+	token.INC:  token.DEC,
+	token.DEC:  token.INC,
+}
+
+// edit replaces src[start:end] with text.
+type edit struct {
+	node       ast.Node
+	start, end int
+	text       string
+}
+
+// Generate returns the mutants of the named functions and methods
+// ("Type.Method") of a file, or of all of its functions if none are named,
+// in source order. file names the mutants; src is its content.
+func Generate(file string, src []byte, functions []string) ([]Mutant, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	want := map[string]bool{}
+	for _, name := range functions {
+		want[name] = true
+	}
+
+	var mutants []Mutant
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		name := goast.FuncName(fn)
+		if len(want) > 0 && !want[name] {
+			continue
+		}
+		for _, e := range edits(fset, fn) {
+			m := Mutant{Mutant: agent.Mutant{
+				ID:       len(mutants) + 1,
+				File:     file,
+				Line:     fset.Position(e.node.Pos()).Line,
+				Function: name,
+			}}
+			nodeStart := fset.Position(e.node.Pos()).Offset
+			nodeEnd := fset.Position(e.node.End()).Offset
+			original := src[nodeStart:nodeEnd]
+			m.Original = snippet(original)
+			m.Mutated = snippet(replace(original, e.start-nodeStart, e.end-nodeStart, e.text))
+			m.Content = replace(src, e.start, e.end, e.text)
+			mutants = append(mutants, m)
+		}
+	}
+	return mutants, nil
+}
+
+// edits returns the mutations of a function body.
+func edits(fset *token.FileSet, fn *ast.FuncDecl) []edit {
+	offset := func(p token.Pos) int { return fset.Position(p).Offset }
+
+	// The last statement of a body may be the return the function needs to
+	// compile, so only earlier returns are dropped.
+	final := map[ast.Stmt]bool{}
+	markFinal := func(body *ast.BlockStmt) {
+		if n := len(body.List); n > 0 {
+			final[body.List[n-1]] = true
+		}
+	}
+	markFinal(fn.Body)
+
+	var out []edit
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			markFinal(n.Body)
+		case *ast.BinaryExpr:
+			if to, ok := flips[n.Op]; ok {
+				start := offset(n.OpPos)
+				out = append(out, edit{n, start, start + len(n.Op.String()), to.String()})
+			}
+		case *ast.IncDecStmt:
+			start := offset(n.TokPos)
+			out = append(out, edit{n, start, start + len(n.Tok.String()), flips[n.Tok].String()})
+		case *ast.UnaryExpr:
+			if n.Op == token.NOT {
+				start := offset(n.OpPos)
+				out = append(out, edit{n, start, start + 1, ""})
+			}
+		case *ast.ReturnStmt:
+			if !final[n] {
+				out = append(out, edit{n, offset(n.Pos()), offset(n.End()), ""})
+			}
+		}
+		return true
+	})
+	return out
+}
+
+func replace(src []byte, start, end int, text string) []byte {
+	out := make([]byte, 0, len(src)-(end-start)+len(text))
+	out = append(out, src[:start]...)
+	out = append(out, text...)
+	return append(out, src[end:]...)
+}
+
+// snippet shortens code to one line for reports and prompts.
+func snippet(code []byte) string {
+	s := strings.Join(strings.Fields(string(bytes.TrimSpace(code))), " ")
+	if s == "" {
+		return "(removed)"
+	}
+	const maxLen = 80
+	if len(s) > maxLen {
+		s = s[:maxLen-3] + "..."
+	}
+	return s
+}
@@ -0,0 +1,182 @@
+package mutation
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"localsprite/internal/agent"
+)
+
+const cartSource = `package cart
+
+func Discount(total int, member bool) int {
+	if total <= 0 {
+		return 0
+	}
+	if !member {
+		return total
+	}
+	return total - total/10
+}
+
+func Other(n int) int { return n * 2 }
+`
+
+func TestGenerate(t *testing.T) {
+	mutants, err := Generate("cart/cart.go", []byte(cartSource), []string{"Discount"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var got []string
+	for _, m := range mutants {
+		if m.Function != "Discount" {
+			t.Errorf("expected only Discount to be mutated, got %s", m.Function)
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), "", m.Content, 0); err != nil {
+			t.Errorf("mutant %s does not parse: %v", m.Mutant, err)
+		}
+		got = append(got, m.Mutant.String())
+	}
+	want := []string{
+		"cart/cart.go:4 (Discount): `total <= 0` changed to `total > 0`",
+		"cart/cart.go:5 (Discount): `return 0` changed to `(removed)`",
+		"cart/cart.go:7 (Discount): `!member` changed to `member`",
+		"cart/cart.go:8 (Discount): `return total` changed to `(removed)`",
+		"cart/cart.go:10 (Discount): `total - total/10` changed to `total + total/10`",
+		"cart/cart.go:10 (Discount): `total/10` changed to `total*10`",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected mutants:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSample(t *testing.T) {
+	mutants := make([]Mutant, 10)
+	for i := range mutants {
+		mutants[i].ID = i + 1
+	}
+	got := sample(mutants, 3)
+	if len(got) != 3 || got[0].ID != 1 || got[1].ID != 4 || got[2].ID != 7 {
+		t.Errorf("expected mutants 1, 4 and 7, got %+v", got)
+	}
+}
+
+// writeModule creates a module whose cart package has a test that only
+// checks members.
+func writeModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":       "module example.com/shop\n\ngo 1.21\n",
+		"cart/cart.go": cartSource,
+		"cart/cart_test.go": `package cart
+
+import "testing"
+
+func TestDiscount(t *testing.T) {
+	if got := Discount(100, true); got != 90 {
+		t.Fatalf("got %d", got)
+	}
+}
+`,
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTester_Report(t *testing.T) {
+	tester := New(writeModule(t), "cart/cart.go", []string{"Discount"})
+	files, command, err := tester.Prepare(context.Background())
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if len(files) != 2*6+1 || files[0].Path != ".localsprite/mutants/1.go" || files[1].Path != ".localsprite/mutants/1.json" {
+		t.Errorf("expected a file and overlay per mutant and a script, got %d files", len(files))
+	}
+	if strings.Join(command, " ") != "sh .localsprite/mutants/run.sh" {
+		t.Errorf("unexpected command %v", command)
+	}
+	if tester.Timeout() != 6*(mutantTimeout+buildTimeout) {
+		t.Errorf("expected a timeout covering every mutant, got %v", tester.Timeout())
+	}
+
+	report, err := tester.Report(&agent.ExecutionResult{Stdout: `localsprite-mutant 1 1
+localsprite-mutant 2 0
+localsprite-mutant 3 0
+localsprite-mutant 4 build
+localsprite-mutant 5 1
+`})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if report.Killed != 2 || len(report.Survived) != 2 || report.Invalid != 1 || report.NotRun != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if report.Survived[0].Line != 5 {
+		t.Errorf("expected the dropped return to survive, got %s", report.Survived[0])
+	}
+
+	if _, err := tester.Report(&agent.ExecutionResult{ExitCode: 1}); err == nil {
+		t.Error("expected error when no mutant was run")
+	}
+}
+
+// TestTester_Script runs the generated script with the local Go toolchain,
+// as the executor would in its container.
+func TestTester_Script(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	root := writeModule(t)
+	tester := New(root, "cart/cart.go", []string{"Discount"})
+	files, command, err := tester.Prepare(context.Background())
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(f.Content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = root
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	report, err := tester.Report(&agent.ExecutionResult{Stdout: string(out)})
+	if err != nil {
+		t.Fatalf("Report failed: %v\n%s", err, out)
+	}
+
+	// The test only covers members with a positive total, so dropping the
+	// returns of the other branches goes unnoticed.
+	if report.Killed != 4 || len(report.Survived) != 2 || report.NotRun != 0 {
+		t.Errorf("unexpected report %+v\n%s", report, out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "cart", "cart.go")); string(data) != cartSource {
+		t.Error("expected the source to be left alone")
+	}
+}
@@ -0,0 +1,159 @@
+package mutation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"localsprite/internal/agent"
+)
+
+const (
+	// mutantDir holds the mutated files, overlays and script in the
+	// workspace. The go tool ignores directories starting with a dot.
+	mutantDir = ".localsprite/mutants"
+
+	// mutantTimeout bounds each mutant's test run, since a mutant may loop
+	// forever; timing out counts as killed.
+	mutantTimeout = 60 * time.Second
+
+	// buildTimeout is allowed on top for building each mutant's test binary,
+	// which go test does before its timeout starts.
+	buildTimeout = 30 * time.Second
+)
+
+// resultLine matches the outcome the script prints for each mutant.
+var resultLine = regexp.MustCompile(`^localsprite-mutant (\d+) (\S+)$`)
+
+// Tester tests generated tests against the mutants of one file.
+type Tester struct {
+	// Root is the local checkout and File the slash-separated file under
+	// test relative to it
+	Root string
+	File string
+
+	// Functions limits mutation to the named functions, all if empty
+	Functions []string
+
+	// Max bounds the number of mutants; larger sets are sampled evenly
+	Max int
+
+	// mutants are those of the last Prepare
+	mutants []Mutant
+}
+
+// New returns a Tester for the functions of file, which is relative to
+// root.
+func New(root, file string, functions []string) *Tester {
+	return &Tester{Root: root, File: filepath.ToSlash(file), Functions: functions, Max: DefaultMaxMutants}
+}
+
+// Prepare implements agent.MutationTester. Besides the mutated files it
+// returns an overlay per mutant and a script that runs the package's tests
+// against each in turn.
+func (t *Tester) Prepare(ctx context.Context) ([]agent.Artifact, []string, error) {
+	src, err := os.ReadFile(filepath.Join(t.Root, filepath.FromSlash(t.File)))
+	if err != nil {
+		return nil, nil, err
+	}
+	mutants, err := Generate(t.File, src, t.Functions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(mutants) == 0 {
+		return nil, nil, fmt.Errorf("no mutants of %s", t.File)
+	}
+	t.mutants = sample(mutants, t.Max)
+
+	pkg := "./" + path.Dir(t.File)
+	var (
+		files  []agent.Artifact
+		script strings.Builder
+	)
+	script.WriteString(`#!/bin/sh
+# Runs the tests against each mutant, laid over the source with -overlay.
+mutant() {
+	out=$(go test -count=1 -vet=off -timeout=` + mutantTimeout.String() + ` -overlay="$2" "$3" 2>&1)
+	code=$?
+	case "$out" in
+	*"[build failed]"*|*"[setup failed]"*) code=build ;;
+	esac
+	echo "localsprite-mutant $1 $code"
+}
+`)
+	for _, m := range t.mutants {
+		base := fmt.Sprintf("%s/%d", mutantDir, m.ID)
+		overlay, err := json.Marshal(map[string]map[string]string{
+			"Replace": {t.File: base + ".go"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files,
+			agent.Artifact{Path: base + ".go", Content: string(m.Content)},
+			agent.Artifact{Path: base + ".json", Content: string(overlay)},
+		)
+		fmt.Fprintf(&script, "mutant %d %s %s\n", m.ID, base+".json", pkg)
+	}
+	files = append(files, agent.Artifact{Path: mutantDir + "/run.sh", Content: script.String()})
+	return files, []string{"sh", mutantDir + "/run.sh"}, nil
+}
+
+// Timeout implements agent.MutationTester. Each mutant prepared may use its
+// whole test timeout, plus the time to build it.
+func (t *Tester) Timeout() time.Duration {
+	return time.Duration(len(t.mutants)) * (mutantTimeout + buildTimeout)
+}
+
+// Report implements agent.MutationTester. A mutant is killed when its test
+// run failed, survives when it passed, and is invalid when it did not
+// build.
+func (t *Tester) Report(result *agent.ExecutionResult) (*agent.MutationReport, error) {
+	if len(t.mutants) == 0 {
+		return nil, fmt.Errorf("no mutants were prepared")
+	}
+	codes := map[string]string{}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if m := resultLine.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			codes[m[1]] = m[2]
+		}
+	}
+
+	report := &agent.MutationReport{}
+	for _, m := range t.mutants {
+		code, ok := codes[strconv.Itoa(m.ID)]
+		switch {
+		case !ok:
+			report.NotRun++
+		case code == "0":
+			report.Survived = append(report.Survived, m.Mutant)
+		case code == "build":
+			report.Invalid++
+		default:
+			report.Killed++
+		}
+	}
+	if report.NotRun == len(t.mutants) {
+		return nil, fmt.Errorf("no mutant was run: %s", result.Summary())
+	}
+	return report, nil
+}
+
+// sample picks n mutants spread evenly over the file.
+func sample(mutants []Mutant, n int) []Mutant {
+	if n <= 0 || len(mutants) <= n {
+		return mutants
+	}
+	out := make([]Mutant, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, mutants[i*len(mutants)/n])
+	}
+	return out
+}
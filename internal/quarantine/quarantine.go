@@ -0,0 +1,142 @@
+// Package quarantine skips flaky tests in generated files: Go tests get a
+// t.Skip call, Playwright and Cypress tests are turned into test.skip and
+// it.skip. It implements agent.Quarantiner.
+package quarantine
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"localsprite/internal/agent"
+)
+
+// Reason is the skip message of quarantined tests.
+const Reason = "quarantined by LocalSprite: flaky"
+
+// Skipper quarantines tests by skipping them in their source.
+type Skipper struct{}
+
+// New returns a Skipper.
+func New() *Skipper {
+	return &Skipper{}
+}
+
+// Quarantine implements agent.Quarantiner. Go subtests are quarantined
+// through their top-level test, which is skipped once however many of its
+// subtests are flaky.
+func (s *Skipper) Quarantine(files []agent.Artifact, tests []agent.TestOutcome) ([]agent.Artifact, error) {
+	out := append([]agent.Artifact(nil), files...)
+	skippedGo := map[string]bool{}
+	for _, t := range tests {
+		top, _, _ := strings.Cut(t.Name, "/")
+		if skippedGo[top] {
+			continue
+		}
+		found := false
+		for i, f := range out {
+			var (
+				content string
+				ok      bool
+				err     error
+			)
+			switch ext := path.Ext(f.Path); {
+			case strings.HasSuffix(f.Path, "_test.go"):
+				content, ok, err = skipGo(f.Content, top)
+				skippedGo[top] = ok
+			case jsExts[ext]:
+				content, ok = skipJS(f.Content, t.Name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Path, err)
+			}
+			if ok {
+				out[i].Content = content
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("test %q not found in the generated files", t.Name)
+		}
+	}
+	return out, nil
+}
+
+// skipGo adds a t.Skip call to the start of the top-level test named top.
+func skipGo(src, top string) (string, bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Name.Name != top || fn.Body == nil {
+			continue
+		}
+		params := fn.Type.Params.List
+		if len(params) != 1 || len(params[0].Names) != 1 || params[0].Names[0].Name == "_" {
+			return "", false, fmt.Errorf("%s does not take a named *testing.T", top)
+		}
+		t := params[0].Names[0].Name
+		at := fset.Position(fn.Body.Lbrace).Offset + 1
+		skip := "\n" + t + ".Skip(" + strconv.Quote(Reason) + ")"
+		if !strings.HasPrefix(src[at:], "\n") {
+			skip += "\n"
+		}
+		skipped := src[:at] + skip + src[at:]
+		formatted, err := format.Source([]byte(skipped))
+		if err != nil {
+			return "", false, err
+		}
+		return string(formatted), true, nil
+	}
+	return "", false, nil
+}
+
+var jsExts = map[string]bool{".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true, ".cjs": true}
+
+// jsTest matches the start of a test declaration, e.g. test('title' or
+// it.only("title".
+var jsTest = regexp.MustCompile("\\b(test|it)(?:\\.only)?\\(\\s*(['\"`])")
+
+// skipJS turns the test titled name into a skipped one. Runners report
+// tests by their full title, e.g. "login › shows form" for Playwright or
+// "login shows form" for Cypress, so ever shorter suffixes of the name are
+// tried as the title.
+func skipJS(src, name string) (string, bool) {
+	for _, title := range titles(name) {
+		for _, m := range jsTest.FindAllStringSubmatchIndex(src, -1) {
+			quote := src[m[4]:m[5]]
+			rest := src[m[1]:]
+			if !strings.HasPrefix(rest, title+quote) {
+				continue
+			}
+			fn := src[m[2]:m[3]]
+			return src[:m[0]] + fn + ".skip(" + src[m[4]:], true
+		}
+	}
+	return "", false
+}
+
+// titles returns the candidate test titles of a full test name.
+func titles(name string) []string {
+	var out []string
+	if i := strings.LastIndex(name, " › "); i >= 0 {
+		out = append(out, name[i+len(" › "):])
+	}
+	out = append(out, name)
+	words := strings.Fields(name)
+	for i := 1; i < len(words); i++ {
+		out = append(out, strings.Join(words[i:], " "))
+	}
+	return out
+}
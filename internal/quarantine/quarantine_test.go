@@ -0,0 +1,69 @@
+package quarantine
+
+import (
+	"strings"
+	"testing"
+
+	"localsprite/internal/agent"
+)
+
+func TestQuarantine_Go(t *testing.T) {
+	files := []agent.Artifact{
+		{Path: "cart/testdata/in.json", Content: "{}"},
+		{Path: "cart/cart_test.go", Content: `package cart
+
+import "testing"
+
+func TestStable(t *testing.T) { t.Log("ok") }
+
+func TestRace(tt *testing.T) {
+	tt.Run("parallel", func(tt *testing.T) {})
+}
+`},
+	}
+
+	out, err := New().Quarantine(files, []agent.TestOutcome{{Name: "TestRace/parallel"}, {Name: "TestStable"}, {Name: "TestRace"}})
+	if err != nil {
+		t.Fatalf("Quarantine failed: %v", err)
+	}
+	want := "func TestRace(tt *testing.T) {\n\ttt.Skip(\"quarantined by LocalSprite: flaky\")\n\ttt.Run("
+	if !strings.Contains(out[1].Content, want) {
+		t.Errorf("expected TestRace to be skipped, got:\n%s", out[1].Content)
+	}
+	if n := strings.Count(out[1].Content, "tt.Skip("); n != 1 {
+		t.Errorf("expected TestRace to be skipped once for it and its subtest, got %d skips", n)
+	}
+	if !strings.Contains(out[1].Content, "func TestStable(t *testing.T) {\n\tt.Skip(\"quarantined by LocalSprite: flaky\")\n\tt.Log(\"ok\")\n}") {
+		t.Errorf("expected one-line TestStable to be skipped, got:\n%s", out[1].Content)
+	}
+	if strings.Contains(files[1].Content, "Skip") {
+		t.Error("expected the input files to be left alone")
+	}
+
+	if _, err := New().Quarantine(files, []agent.TestOutcome{{Name: "TestMissing"}}); err == nil {
+		t.Error("expected error for a test that is not in the files")
+	}
+}
+
+func TestQuarantine_JS(t *testing.T) {
+	files := []agent.Artifact{{Path: "e2e/login.spec.ts", Content: `test.describe('login', () => {
+  test('shows form', async ({ page }) => {});
+  test.only("logs in", async ({ page }) => {});
+});
+it('works', () => {});
+`}}
+
+	out, err := New().Quarantine(files, []agent.TestOutcome{
+		{Name: "login › shows form"},
+		{Name: "login logs in"},
+		{Name: "works"},
+	})
+	if err != nil {
+		t.Fatalf("Quarantine failed: %v", err)
+	}
+	for _, want := range []string{"test.skip('shows form'", `test.skip("logs in"`, "it.skip('works'", "test.describe('login'"} {
+		if !strings.Contains(out[0].Content, want) {
+			t.Errorf("expected %s, got:\n%s", want, out[0].Content)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/config"
+	"localsprite/internal/govalidate"
+	"localsprite/internal/quarantine"
+	"localsprite/pkg/providers/coder"
+	"localsprite/pkg/providers/executor"
+	"localsprite/pkg/providers/planner"
+)
+
+// defaultCoverageRounds bounds coverage-guided planning when a profile sets
+// a coverage target without a number of rounds.
+const defaultCoverageRounds = 3
+
+// defaultMutationRounds bounds how often surviving mutants are fed back to
+// the coder when a profile enables mutation testing.
+const defaultMutationRounds = 1
+
+// PlannerFactory builds a Planner from a profile's planner section.
+type PlannerFactory func(cfg config.ProviderConfig) (agent.Planner, error)
+
+// CoderFactory builds a Coder from a profile's coder section.
+type CoderFactory func(cfg config.ProviderConfig) (agent.Coder, error)
+
+// ExecutorFactory builds an Executor from a profile's executor section.
+type ExecutorFactory func(cfg config.ProviderConfig) (agent.Executor, error)
+
+// Registry maps ProviderConfig.Type values to provider constructors.
+type Registry struct {
+	planners  map[string]PlannerFactory
+	coders    map[string]CoderFactory
+	executors map[string]ExecutorFactory
+}
+
+// New returns an empty registry.
+func New() *Registry {
+	return &Registry{
+		planners:  map[string]PlannerFactory{},
+		coders:    map[string]CoderFactory{},
+		executors: map[string]ExecutorFactory{},
+	}
+}
+
+// Default returns a registry with all built-in providers registered.
+func Default() *Registry {
+	r := New()
+
+	r.RegisterPlanner("gemini", func(cfg config.ProviderConfig) (agent.Planner, error) {
+		apiKey := paramOrEnv(cfg.Params, "api_key", "GEMINI_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("gemini planner requires params.api_key or GEMINI_API_KEY")
+		}
+		p := planner.NewGeminiPlanner(cfg.Model)
+		p.APIKey = apiKey
+		if baseURL := cfg.Params["base_url"]; baseURL != "" {
+			p.BaseURL = baseURL
+		}
+		return p, nil
+	})
+	r.RegisterPlanner("local", func(cfg config.ProviderConfig) (agent.Planner, error) {
+		endpoint := cfg.Params["endpoint"]
+		if endpoint == "" {
+			return nil, fmt.Errorf("local planner requires params.endpoint")
+		}
+		p := planner.NewLocalLLMPlanner(endpoint, cfg.Model)
+		p.Client.APIKey = cfg.Params["api_key"]
+		return p, nil
+	})
+
+	r.RegisterCoder("local", func(cfg config.ProviderConfig) (agent.Coder, error) {
+		endpoint := cfg.Params["endpoint"]
+		if endpoint == "" {
+			return nil, fmt.Errorf("local coder requires params.endpoint")
+		}
+		c := coder.NewLocalLLMCoder(endpoint, cfg.Model)
+		c.Client.APIKey = cfg.Params["api_key"]
+		c.SystemPrompt = cfg.Params["system_prompt"]
+		return c, nil
+	})
+	r.RegisterCoder("anthropic", func(cfg config.ProviderConfig) (agent.Coder, error) {
+		apiKey := paramOrEnv(cfg.Params, "api_key", "ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("anthropic coder requires params.api_key or ANTHROPIC_API_KEY")
+		}
+		c := coder.NewAnthropicCoder(cfg.Model, apiKey)
+		if baseURL := cfg.Params["base_url"]; baseURL != "" {
+			c.BaseURL = baseURL
+		}
+		c.SystemPrompt = cfg.Params["system_prompt"]
+		if err := intParam(cfg.Params, "max_tokens", &c.MaxTokens); err != nil {
+			return nil, err
+		}
+		if err := intParam(cfg.Params, "max_retries", &c.MaxRetries); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+	r.RegisterCoder("bedrock", func(cfg config.ProviderConfig) (agent.Coder, error) {
+		region := paramOrEnv(cfg.Params, "region", "AWS_REGION")
+		if region == "" {
+			return nil, fmt.Errorf("bedrock coder requires params.region or AWS_REGION")
+		}
+		c := coder.NewBedrockCoder(cfg.Model, region)
+		c.Endpoint = paramOrEnv(cfg.Params, "endpoint", "AWS_ENDPOINT_URL_BEDROCK_RUNTIME")
+		c.Profile = cfg.Params["profile"]
+		c.SystemPrompt = cfg.Params["system_prompt"]
+		if err := intParam(cfg.Params, "max_tokens", &c.MaxTokens); err != nil {
+			return nil, err
+		}
+		if err := intParam(cfg.Params, "max_retries", &c.MaxRetries); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+
+	r.RegisterExecutor("local_docker", func(cfg config.ProviderConfig) (agent.Executor, error) {
+		execCfg, err := executor.ConfigFromParams(cfg.Params)
+		if err != nil {
+			return nil, err
+		}
+		if execCfg.Image == "" {
+			execCfg.Image = executor.DefaultGoConfig().Image
+		}
+		return executor.NewLocalDockerExecutor(execCfg), nil
+	})
+	r.RegisterExecutor("remote_docker", func(cfg config.ProviderConfig) (agent.Executor, error) {
+		execCfg, err := executor.ConfigFromParams(cfg.Params)
+		if err != nil {
+			return nil, err
+		}
+		if execCfg.Host == "" {
+			return nil, fmt.Errorf("remote_docker executor requires params.host")
+		}
+		if execCfg.Image == "" {
+			execCfg.Image = executor.DefaultGoConfig().Image
+		}
+		return executor.NewRemoteDockerExecutor(execCfg), nil
+	})
+
+	return r
+}
+
+// RegisterPlanner adds or replaces the factory for a planner type.
+func (r *Registry) RegisterPlanner(typ string, f PlannerFactory) {
+	r.planners[typ] = f
+}
+
+// RegisterCoder adds or replaces the factory for a coder type.
+func (r *Registry) RegisterCoder(typ string, f CoderFactory) {
+	r.coders[typ] = f
+}
+
+// RegisterExecutor adds or replaces the factory for an executor type.
+func (r *Registry) RegisterExecutor(typ string, f ExecutorFactory) {
+	r.executors[typ] = f
+}
+
+// Planner constructs the planner described by cfg.
+func (r *Registry) Planner(cfg config.ProviderConfig) (agent.Planner, error) {
+	f, ok := r.planners[cfg.Type]
+	if !ok {
+		return nil, unknownType("planner", cfg.Type, keys(r.planners))
+	}
+	p, err := f(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s planner: %w", cfg.Type, err)
+	}
+	return p, nil
+}
+
+// Coder constructs the coder described by cfg.
+func (r *Registry) Coder(cfg config.ProviderConfig) (agent.Coder, error) {
+	f, ok := r.coders[cfg.Type]
+	if !ok {
+		return nil, unknownType("coder", cfg.Type, keys(r.coders))
+	}
+	c, err := f(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s coder: %w", cfg.Type, err)
+	}
+	return c, nil
+}
+
+// Executor constructs the executor described by cfg.
+func (r *Registry) Executor(cfg config.ProviderConfig) (agent.Executor, error) {
+	f, ok := r.executors[cfg.Type]
+	if !ok {
+		return nil, unknownType("executor", cfg.Type, keys(r.executors))
+	}
+	e, err := f(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s executor: %w", cfg.Type, err)
+	}
+	return e, nil
+}
+
+// BuildAgent wires the planner, coder and executor of a profile into an Agent.
+func (r *Registry) BuildAgent(profile config.Profile) (*agent.Agent, error) {
+	p, err := r.Planner(profile.Planner)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.Coder(profile.Coder)
+	if err != nil {
+		return nil, err
+	}
+	e, err := r.Executor(profile.Executor)
+	if err != nil {
+		return nil, err
+	}
+	a := agent.NewAgent(p, c, e)
+	if profile.Agent.MaxIterations > 0 {
+		a.MaxIterations = profile.Agent.MaxIterations
+	}
+	a.PlanTimeout = profile.Agent.PlanTimeout
+	a.CodeTimeout = profile.Agent.CodeTimeout
+	a.Quarantiner = quarantine.New()
+	if profile.Agent.Validate {
+		a.Validator = govalidate.New(profile.Executor.Params["test_file_pattern"])
+	}
+	if target := profile.Agent.CoverageTarget; target > 0 {
+		if target > 100 {
+			return nil, fmt.Errorf("agent.coverage_target %v must be a percentage up to 100", target)
+		}
+		if on, _ := strconv.ParseBool(profile.Executor.Params["coverage"]); !on {
+			return nil, errors.New("agent.coverage_target requires the executor param coverage: \"true\"")
+		}
+		a.CoverageTarget = target
+		a.CoverageRounds = profile.Agent.CoverageRounds
+		if a.CoverageRounds <= 0 {
+			a.CoverageRounds = defaultCoverageRounds
+		}
+	}
+	if profile.Agent.Mutation {
+		a.MutationTarget = profile.Agent.MutationTarget
+		if a.MutationTarget <= 0 {
+			a.MutationTarget = 100
+		}
+		if a.MutationTarget > 100 {
+			return nil, fmt.Errorf("agent.mutation_target %v must be a percentage up to 100", a.MutationTarget)
+		}
+		a.MutationRounds = profile.Agent.MutationRounds
+		if a.MutationRounds <= 0 {
+			a.MutationRounds = defaultMutationRounds
+		}
+	}
+	return a, nil
+}
+
+func unknownType(kind, typ string, known []string) error {
+	if typ == "" {
+		return fmt.Errorf("%s type is not set (known types: %s)", kind, strings.Join(known, ", "))
+	}
+	return fmt.Errorf("unknown %s type %q (known types: %s)", kind, typ, strings.Join(known, ", "))
+}
+
+func keys[T any](m map[string]T) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// intParam parses params[key] into dst when it is set.
+func intParam(params map[string]string, key string, dst *int) error {
+	v := params[key]
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid params.%s %q: must be a non-negative integer", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func paramOrEnv(params map[string]string, key, env string) string {
+	if v := params[key]; v != "" {
+		return v
+	}
+	return os.Getenv(env)
+}
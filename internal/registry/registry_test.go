@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/config"
+	"localsprite/internal/govalidate"
+)
+
+func TestDefault_BuildsWorkProfile(t *testing.T) {
+	profile := config.Profile{
+		Planner: config.ProviderConfig{
+			Type:   "gemini",
+			Model:  "gemini-test",
+			Params: map[string]string{"api_key": "test-key"},
+		},
+		Coder: config.ProviderConfig{
+			Type:   "bedrock",
+			Model:  "claude-test",
+			Params: map[string]string{"region": "us-east-1"},
+		},
+		Executor: config.ProviderConfig{
+			Type:   "local_docker",
+			Params: map[string]string{"command": "go,test,./..."},
+		},
+	}
+
+	a, err := Default().BuildAgent(profile)
+	if err != nil {
+		t.Fatalf("BuildAgent failed: %v", err)
+	}
+	if a.Planner == nil || a.Coder == nil || a.Executor == nil {
+		t.Errorf("expected all components to be set, got %+v", a)
+	}
+}
+
+func TestDefault_Validator(t *testing.T) {
+	local := config.ProviderConfig{Type: "local", Params: map[string]string{"endpoint": "http://localhost:11434/v1"}}
+	profile := config.Profile{
+		Planner: local,
+		Coder:   local,
+		Executor: config.ProviderConfig{
+			Type:   "local_docker",
+			Params: map[string]string{"test_file_pattern": "cart_gen_test.go"},
+		},
+	}
+
+	a, err := Default().BuildAgent(profile)
+	if err != nil {
+		t.Fatalf("BuildAgent failed: %v", err)
+	}
+	if a.Validator != nil {
+		t.Error("expected no validator unless enabled")
+	}
+
+	profile.Agent.Validate = true
+	if a, err = Default().BuildAgent(profile); err != nil {
+		t.Fatalf("BuildAgent failed: %v", err)
+	}
+	v, ok := a.Validator.(*govalidate.Validator)
+	if !ok || v.TestFile != "cart_gen_test.go" {
+		t.Errorf("expected a Go validator naming unnamed files like the executor, got %+v", a.Validator)
+	}
+}
+
+func TestDefault_BuildsHomeProfile(t *testing.T) {
+	local := config.ProviderConfig{
+		Type:   "local",
+		Model:  "gemma3:12b",
+		Params: map[string]string{"endpoint": "http://localhost:11434/v1"},
+	}
+	profile := config.Profile{
+		Planner: local,
+		Coder:   local,
+		Executor: config.ProviderConfig{
+			Type:   "remote_docker",
+			Params: map[string]string{"host": "ssh://imperial-construct"},
+		},
+	}
+
+	if _, err := Default().BuildAgent(profile); err != nil {
+		t.Fatalf("BuildAgent failed: %v", err)
+	}
+}
+
+func TestRegistry_UnknownType(t *testing.T) {
+	_, err := Default().Coder(config.ProviderConfig{Type: "gpt"})
+	if err == nil {
+		t.Fatal("expected error for unknown coder type")
+	}
+	if !strings.Contains(err.Error(), `unknown coder type "gpt"`) || !strings.Contains(err.Error(), "bedrock") {
+		t.Errorf("expected error naming the type and known types, got: %v", err)
+	}
+}
+
+func TestRegistry_RemoteDockerRequiresHost(t *testing.T) {
+	_, err := Default().Executor(config.ProviderConfig{Type: "remote_docker"})
+	if err == nil || !strings.Contains(err.Error(), "params.host") {
+		t.Errorf("expected missing host error, got: %v", err)
+	}
+}
+
+type stubPlanner struct{}
+
+func (stubPlanner) Plan(context.Context, string) (*agent.TestPlan, error) { return nil, nil }
+
+func TestRegistry_RegisterPlanner(t *testing.T) {
+	r := New()
+	r.RegisterPlanner("stub", func(config.ProviderConfig) (agent.Planner, error) {
+		return stubPlanner{}, nil
+	})
+
+	p, err := r.Planner(config.ProviderConfig{Type: "stub"})
+	if err != nil {
+		t.Fatalf("Planner failed: %v", err)
+	}
+	if _, ok := p.(stubPlanner); !ok {
+		t.Errorf("expected stubPlanner, got %T", p)
+	}
+}
+
+func TestDefault_CoverageTarget(t *testing.T) {
+	local := config.ProviderConfig{Type: "local", Params: map[string]string{"endpoint": "http://localhost:11434/v1"}}
+	profile := config.Profile{
+		Planner:  local,
+		Coder:    local,
+		Executor: config.ProviderConfig{Type: "local_docker", Params: map[string]string{}},
+		Agent:    config.AgentConfig{CoverageTarget: 80},
+	}
+
+	if _, err := Default().BuildAgent(profile); err == nil || !strings.Contains(err.Error(), "coverage") {
+		t.Errorf("expected coverage_target to require executor coverage, got %v", err)
+	}
+
+	profile.Executor.Params["coverage"] = "true"
+	a, err := Default().BuildAgent(profile)
+	if err != nil {
+		t.Fatalf("BuildAgent failed: %v", err)
+	}
+	if a.CoverageTarget != 80 || a.CoverageRounds != defaultCoverageRounds {
+		t.Errorf("expected target 80 with default rounds, got %v/%d", a.CoverageTarget, a.CoverageRounds)
+	}
+}
@@ -0,0 +1,139 @@
+package repocontext
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single .gitignore pattern. base is the slash-separated
+// directory of the .gitignore file relative to the repository root.
+type ignoreRule struct {
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignorer implements the subset of gitignore semantics needed to skip build
+// output and dependencies: comments, negation, directory-only patterns,
+// anchoring, and "*", "?", "[...]" and "**" globs. Rules are evaluated in
+// order and the last match wins, so nested .gitignore files override their
+// parents.
+type ignorer struct {
+	rules []ignoreRule
+}
+
+// load reads the .gitignore file of dir (relative to root), if any.
+func (ig *ignorer) load(root, dir string) error {
+	return ig.loadFile(filepath.Join(root, filepath.FromSlash(dir), ".gitignore"), dir)
+}
+
+func (ig *ignorer) loadFile(file, base string) error {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ig.add(base, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func (ig *ignorer) add(base, line string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	rule := ignoreRule{base: strings.Trim(base, "/")}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// A slash anywhere but at the end anchors the pattern to the directory
+	// of the .gitignore file.
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return
+	}
+	rule.pattern = line
+	ig.rules = append(ig.rules, rule)
+}
+
+// ignored reports whether the slash-separated path rel should be skipped.
+func (ig *ignorer) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range ig.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = rel[len(r.base)+1:]
+		}
+
+		var match bool
+		if r.anchored {
+			match = matchGlob(r.pattern, sub)
+		} else {
+			match = matchGlob(r.pattern, path.Base(sub))
+		}
+		if match {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matchGlob matches a slash-separated name against a pattern in which "**"
+// spans any number of path segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,258 @@
+package repocontext
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"localsprite/internal/goast"
+)
+
+// parseGoMod reads the module path, go version and direct requirements of a
+// go.mod file.
+func parseGoMod(file string) (*Module, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mod := &Module{}
+	inRequire := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		indirect := strings.HasSuffix(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		switch {
+		case inRequire:
+			if line == ")" {
+				inRequire = false
+			} else if line != "" && !indirect {
+				mod.Requires = append(mod.Requires, strings.Join(strings.Fields(line), " "))
+			}
+		case strings.HasPrefix(line, "module "):
+			mod.Path, _ = unquote(strings.TrimSpace(strings.TrimPrefix(line, "module ")))
+		case strings.HasPrefix(line, "go "):
+			mod.GoVersion = strings.TrimSpace(strings.TrimPrefix(line, "go "))
+		case line == "require (":
+			inRequire = true
+		case strings.HasPrefix(line, "require ") && !indirect:
+			mod.Requires = append(mod.Requires, strings.Join(strings.Fields(strings.TrimPrefix(line, "require ")), " "))
+		}
+	}
+	return mod, scanner.Err()
+}
+
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "`") {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
+
+// parsePackage summarizes the Go files of a package directory. Files that
+// do not parse are listed but contribute no symbols.
+func parsePackage(root, dir string, files []string) (*Package, error) {
+	sort.Strings(files)
+	pkg := &Package{Dir: dir}
+	fset := token.NewFileSet()
+	imports := map[string]bool{}
+
+	for _, name := range files {
+		isTest := strings.HasSuffix(name, "_test.go")
+		if isTest {
+			pkg.TestFiles = append(pkg.TestFiles, name)
+		} else {
+			pkg.Files = append(pkg.Files, name)
+		}
+
+		src, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), name))
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		if isTest {
+			pkg.Tests = append(pkg.Tests, testFuncs(f)...)
+			continue
+		}
+		if pkg.Name == "" {
+			pkg.Name = f.Name.Name
+		}
+		for _, imp := range f.Imports {
+			if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+				imports[p] = true
+			}
+		}
+		pkg.Symbols = append(pkg.Symbols, exportedSymbols(fset, f, name)...)
+	}
+
+	for p := range imports {
+		pkg.Imports = append(pkg.Imports, p)
+	}
+	sort.Strings(pkg.Imports)
+	return pkg, nil
+}
+
+// testFuncs returns the names of the test, benchmark, fuzz and example
+// functions declared in f.
+func testFuncs(f *ast.File) []string {
+	var names []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+			if strings.HasPrefix(fn.Name.Name, prefix) {
+				names = append(names, fn.Name.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func exportedSymbols(fset *token.FileSet, f *ast.File, file string) []Symbol {
+	var symbols []Symbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			sym := Symbol{Name: d.Name.Name, Kind: KindFunc, Doc: firstSentence(d.Doc), File: file}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := goast.ReceiverType(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				sym.Name = recv + "." + d.Name.Name
+				sym.Kind = KindMethod
+			}
+			sig := *d
+			sig.Doc, sig.Body = nil, nil
+			sym.Signature = nodeString(fset, &sig)
+			symbols = append(symbols, sym)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if !s.Name.IsExported() {
+						continue
+					}
+					doc := s.Doc
+					if doc == nil {
+						doc = d.Doc
+					}
+					symbols = append(symbols, Symbol{
+						Name:      s.Name.Name,
+						Kind:      KindType,
+						Signature: typeSignature(fset, s),
+						Doc:       firstSentence(doc),
+						File:      file,
+					})
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, name := range s.Names {
+						if name.IsExported() {
+							symbols = append(symbols, Symbol{
+								Name:      name.Name,
+								Kind:      kind,
+								Signature: kind + " " + name.Name,
+								File:      file,
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// typeSignature renders a type declaration without struct fields, listing
+// the method names of interfaces.
+func typeSignature(fset *token.FileSet, s *ast.TypeSpec) string {
+	prefix := "type " + s.Name.Name
+	if s.TypeParams != nil {
+		var params []string
+		for _, f := range s.TypeParams.List {
+			var names []string
+			for _, n := range f.Names {
+				names = append(names, n.Name)
+			}
+			params = append(params, strings.Join(names, ", ")+" "+nodeString(fset, f.Type))
+		}
+		prefix += "[" + strings.Join(params, ", ") + "]"
+	}
+	if s.Assign.IsValid() {
+		return prefix + " = " + nodeString(fset, s.Type)
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		return prefix + " struct"
+	case *ast.InterfaceType:
+		var methods []string
+		for _, m := range t.Methods.List {
+			for _, name := range m.Names {
+				methods = append(methods, name.Name)
+			}
+		}
+		if len(methods) == 0 {
+			return prefix + " interface"
+		}
+		return prefix + " interface{ " + strings.Join(methods, "; ") + " }"
+	default:
+		return prefix + " " + nodeString(fset, s.Type)
+	}
+}
+
+// nodeString prints node on a single line.
+func nodeString(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func firstSentence(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	text := strings.Join(strings.Fields(doc.Text()), " ")
+	// A sentence ends at a period followed by a capitalized word, which
+	// skips abbreviations such as "e.g. foo".
+	for i := 0; i+2 < len(text); i++ {
+		if text[i] == '.' && text[i+1] == ' ' && unicode.IsUpper(rune(text[i+2])) {
+			text = text[:i+1]
+			break
+		}
+	}
+	const maxDoc = 120
+	if len(text) > maxDoc {
+		text = text[:maxDoc] + "..."
+	}
+	return text
+}
@@ -0,0 +1,259 @@
+package repocontext
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultTokenBudget is used when Options.TokenBudget is zero. It leaves
+// room for the prompt and response in an 8k-16k context window.
+const DefaultTokenBudget = 6000
+
+// Options tune the rendered context.
+type Options struct {
+	// Target is the repo-relative file the run focuses on. Its package is
+	// rendered first, followed by the packages it imports and those that
+	// import it.
+	Target string
+
+	// TokenBudget bounds the estimated size of the rendered context
+	// (default: DefaultTokenBudget)
+	TokenBudget int
+}
+
+// Build analyzes the checkout at root and renders its context.
+func Build(root string, opts Options) (string, error) {
+	repo, err := Analyze(root)
+	if err != nil {
+		return "", err
+	}
+	return repo.Render(opts), nil
+}
+
+// EstimateTokens approximates the number of LLM tokens in s using the
+// common rule of thumb of four bytes per token.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// Render packs the summary into a Markdown context of at most
+// opts.TokenBudget estimated tokens. Sections are added in priority order;
+// packages that do not fit are omitted and counted at the end, and the
+// target package is truncated rather than dropped.
+func (r *Repo) Render(opts Options) string {
+	budget := opts.TokenBudget
+	if budget <= 0 {
+		budget = DefaultTokenBudget
+	}
+	// Reserve room for the omission note.
+	const reserve = 32
+	budget -= reserve
+
+	var (
+		b       strings.Builder
+		used    int
+		omitted int
+	)
+	add := func(chunk string, truncate bool) {
+		cost := EstimateTokens(chunk)
+		if used+cost > budget {
+			if !truncate {
+				omitted++
+				return
+			}
+			chunk = truncateLines(chunk, (budget-used)*4)
+			cost = EstimateTokens(chunk)
+		}
+		b.WriteString(chunk)
+		used += cost
+	}
+
+	target := r.Package(opts.Target)
+	add(r.header(opts.Target, target), true)
+
+	for i, pkg := range r.orderPackages(target) {
+		add(renderPackage(pkg), i == 0 && pkg == target)
+	}
+	if layout := r.layout(); layout != "" {
+		add(layout, false)
+	}
+
+	if omitted > 0 {
+		fmt.Fprintf(&b, "\n(%d section(s) omitted to fit the context budget)\n", omitted)
+	}
+	return b.String()
+}
+
+func (r *Repo) header(targetFile string, target *Package) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Repository %s\n\n", path.Base(r.Root))
+	for _, m := range r.Modules {
+		fmt.Fprintf(&b, "Module %s", m.Path)
+		if m.GoVersion != "" {
+			fmt.Fprintf(&b, " (go %s)", m.GoVersion)
+		}
+		if m.Dir != "." {
+			fmt.Fprintf(&b, " in %s/", m.Dir)
+		}
+		b.WriteString("\n")
+		if len(m.Requires) > 0 {
+			fmt.Fprintf(&b, "Requires: %s\n", strings.Join(m.Requires, ", "))
+		}
+	}
+
+	if targetFile != "" {
+		fmt.Fprintf(&b, "Target file: %s", targetFile)
+		if target != nil {
+			fmt.Fprintf(&b, " (package %s, %s)", target.Name, target.ImportPath)
+		}
+		b.WriteString("\n")
+	}
+
+	tested := 0
+	for _, pkg := range r.Packages {
+		if len(pkg.TestFiles) > 0 {
+			tested++
+		}
+	}
+	fmt.Fprintf(&b, "Files: %d; Go packages: %d (%d with tests)\n", len(r.Files), len(r.Packages), tested)
+	return b.String()
+}
+
+// orderPackages puts the target first, then the packages it imports, then
+// those that import it, then the rest in path order.
+func (r *Repo) orderPackages(target *Package) []*Package {
+	if target == nil {
+		return r.Packages
+	}
+
+	rank := func(pkg *Package) int {
+		switch {
+		case pkg == target:
+			return 0
+		case contains(target.Imports, pkg.ImportPath):
+			return 1
+		case contains(pkg.Imports, target.ImportPath):
+			return 2
+		default:
+			return 3
+		}
+	}
+	out := append([]*Package(nil), r.Packages...)
+	sort.SliceStable(out, func(i, j int) bool { return rank(out[i]) < rank(out[j]) })
+	return out
+}
+
+func renderPackage(pkg *Package) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n## package %s (%s)\n", pkg.Name, pkg.ImportPath)
+	if len(pkg.Files) > 0 {
+		fmt.Fprintf(&b, "Files: %s\n", strings.Join(pkg.Files, ", "))
+	}
+	if len(pkg.Imports) > 0 {
+		fmt.Fprintf(&b, "Imports: %s\n", strings.Join(pkg.Imports, ", "))
+	}
+	if len(pkg.Symbols) > 0 {
+		b.WriteString("Exported:\n")
+		for _, sym := range pkg.Symbols {
+			fmt.Fprintf(&b, "- %s", sym.Signature)
+			if sym.Doc != "" {
+				fmt.Fprintf(&b, " // %s", sym.Doc)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(pkg.TestFiles) > 0 {
+		fmt.Fprintf(&b, "Tests (%s): %s\n", strings.Join(pkg.TestFiles, ", "), strings.Join(pkg.Tests, ", "))
+	} else {
+		b.WriteString("Tests: none\n")
+	}
+	return b.String()
+}
+
+// layout summarizes files outside Go packages by directory, e.g. for
+// Playwright or Cypress projects, and lists their test files.
+func (r *Repo) layout() string {
+	type dirInfo struct {
+		files int
+		tests []string
+	}
+	dirs := map[string]*dirInfo{}
+	for _, f := range r.Files {
+		if strings.HasSuffix(f, ".go") || path.Base(f) == "go.mod" || path.Base(f) == "go.sum" {
+			continue
+		}
+		dir := path.Dir(f)
+		d, ok := dirs[dir]
+		if !ok {
+			d = &dirInfo{}
+			dirs[dir] = d
+		}
+		d.files++
+		if IsTestFile(f) {
+			d.tests = append(d.tests, path.Base(f))
+		}
+	}
+	if len(dirs) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("\n## Other files\n")
+	for _, dir := range names {
+		d := dirs[dir]
+		fmt.Fprintf(&b, "- %s/ (%d files)", dir, d.files)
+		if len(d.tests) > 0 {
+			fmt.Fprintf(&b, " tests: %s", strings.Join(d.tests, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// IsTestFile reports whether name follows the naming conventions of Go or
+// common JavaScript and TypeScript test runners.
+func IsTestFile(name string) bool {
+	base := path.Base(name)
+	if strings.HasSuffix(base, "_test.go") {
+		return true
+	}
+	for _, marker := range []string{".test.", ".spec.", ".cy."} {
+		if strings.Contains(base, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateLines cuts s to at most max bytes at a line boundary.
+func truncateLines(s string, max int) string {
+	const marker = "...\n"
+	if len(s) <= max {
+		return s
+	}
+	if max < len(marker) {
+		return ""
+	}
+	cut := s[:max-len(marker)]
+	if i := strings.LastIndexByte(cut, '\n'); i >= 0 {
+		cut = cut[:i+1]
+	}
+	return cut + marker
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+// Package repocontext analyzes a repository checkout and renders a compact,
+// token-budgeted summary of it for the planner.
+package repocontext
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Repo summarizes a repository checkout.
+type Repo struct {
+	// Root is the absolute path of the checkout
+	Root string
+
+	Modules  []*Module
+	Packages []*Package
+
+	// Files lists every file that is not ignored, relative to Root
+	Files []string
+}
+
+// Module is a Go module found in the checkout.
+type Module struct {
+	// Dir is the slash-separated directory of go.mod relative to the root
+	Dir       string
+	Path      string
+	GoVersion string
+
+	// Requires lists direct dependencies as "path version"
+	Requires []string
+}
+
+// Package is a Go package directory.
+type Package struct {
+	// Dir is the slash-separated package directory relative to the root
+	Dir        string
+	ImportPath string
+	Name       string
+
+	// Files and TestFiles are file names within Dir
+	Files     []string
+	TestFiles []string
+
+	// Symbols are the exported declarations of the non-test files
+	Symbols []Symbol
+
+	// Tests are the Test, Benchmark, Fuzz and Example functions
+	Tests []string
+
+	// Imports is the sorted union of the non-test files' imports
+	Imports []string
+}
+
+// Symbol is an exported declaration.
+type Symbol struct {
+	// Name is the identifier, qualified by the receiver type for methods
+	// (e.g. "Client.Do")
+	Name      string
+	Kind      string
+	Signature string
+
+	// Doc is the first sentence of the doc comment
+	Doc  string
+	File string
+}
+
+// Symbol kinds.
+const (
+	KindFunc   = "func"
+	KindMethod = "method"
+	KindType   = "type"
+	KindConst  = "const"
+	KindVar    = "var"
+)
+
+// Analyze walks the checkout at root, as ListFiles does, and summarizes its
+// Go modules and packages.
+func Analyze(root string) (*Repo, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ListFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &Repo{Root: root, Files: files}
+	goFiles := map[string][]string{}
+	for _, rel := range files {
+		dir, name := path.Dir(rel), path.Base(rel)
+		switch {
+		case name == "go.mod":
+			mod, err := parseGoMod(filepath.Join(root, filepath.FromSlash(rel)))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rel, err)
+			}
+			mod.Dir = dir
+			repo.Modules = append(repo.Modules, mod)
+		case strings.HasSuffix(name, ".go") && isPackageDir(dir):
+			goFiles[dir] = append(goFiles[dir], name)
+		}
+	}
+
+	dirs := make([]string, 0, len(goFiles))
+	for dir := range goFiles {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		pkg, err := parsePackage(root, dir, goFiles[dir])
+		if err != nil {
+			return nil, err
+		}
+		pkg.ImportPath = repo.importPath(dir)
+		repo.Packages = append(repo.Packages, pkg)
+	}
+	return repo, nil
+}
+
+// ListFiles returns the slash-separated paths, relative to root and in
+// lexical order, of the regular files in the checkout, skipping .git and
+// anything excluded by .gitignore files or .git/info/exclude.
+func ListFiles(root string) ([]string, error) {
+	ig := &ignorer{}
+	if err := ig.loadFile(filepath.Join(root, ".git", "info", "exclude"), ""); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == "." {
+				return ig.load(root, "")
+			}
+			if d.Name() == ".git" || ig.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			return ig.load(root, rel)
+		}
+		if !ig.ignored(rel, false) && d.Type().IsRegular() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// Package returns the package containing the repo-relative file, or nil.
+func (r *Repo) Package(file string) *Package {
+	return r.PackageDir(path.Dir(filepath.ToSlash(file)))
+}
+
+// PackageDir returns the package in the repo-relative directory, or nil.
+func (r *Repo) PackageDir(dir string) *Package {
+	for _, pkg := range r.Packages {
+		if pkg.Dir == dir {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// module returns the innermost module containing dir, or nil.
+func (r *Repo) module(dir string) *Module {
+	var best *Module
+	for _, m := range r.Modules {
+		if within(dir, m.Dir) && (best == nil || len(m.Dir) > len(best.Dir)) {
+			best = m
+		}
+	}
+	return best
+}
+
+func (r *Repo) importPath(dir string) string {
+	m := r.module(dir)
+	if m == nil || m.Path == "" {
+		return dir
+	}
+	if dir == m.Dir {
+		return m.Path
+	}
+	if m.Dir == "." {
+		return m.Path + "/" + dir
+	}
+	return m.Path + "/" + strings.TrimPrefix(dir, m.Dir+"/")
+}
+
+// within reports whether the slash-separated dir is base or below it.
+func within(dir, base string) bool {
+	return base == "." || dir == base || strings.HasPrefix(dir, base+"/")
+}
+
+// isPackageDir mirrors the go tool, which ignores testdata and directories
+// starting with "." or "_".
+func isPackageDir(dir string) bool {
+	if dir == "." {
+		return true
+	}
+	for _, elem := range strings.Split(dir, "/") {
+		if elem == "testdata" || strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_") {
+			return false
+		}
+	}
+	return true
+}
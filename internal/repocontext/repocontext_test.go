@@ -0,0 +1,201 @@
+package repocontext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testRepo(t *testing.T) string {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module example.com/shop\n\ngo 1.24\n\nrequire (\n\tgithub.com/google/uuid v1.6.0\n\tgolang.org/x/text v0.31.0 // indirect\n)\n",
+		".gitignore": "bin/\n*.log\n/generated\n!keep.log\n",
+		"cart/cart.go": `package cart
+
+import "example.com/shop/money"
+
+// Cart holds the items of an order. It is not safe for concurrent use.
+type Cart struct{ items []money.Cents }
+
+// Add appends an item, e.g. a discount. Prices are in cents.
+func (c *Cart) Add(price money.Cents) { c.items = append(c.items, price) }
+
+func (c *Cart) reset() {}
+
+// Total sums the cart.
+func Total(c *Cart) money.Cents { return 0 }
+
+const MaxItems = 100
+`,
+		"cart/cart_test.go":   "package cart\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {}\n\nfunc helper() {}\n",
+		"money/money.go":      "package money\n\ntype Cents int64\n\ntype Formatter interface {\n\tFormat(Cents) string\n}\n\ntype Set[K comparable, V any] map[K]V\n",
+		"report/report.go":    "package report\n\nimport _ \"example.com/shop/cart\"\n",
+		"broken/broken.go":    "package broken\n\nfunc (",
+		"testdata/fixture.go": "package fixture\n",
+		"bin/shop":            "binary",
+		"debug.log":           "log",
+		"keep.log":            "kept",
+		"generated/gen.go":    "package generated\n",
+		"web/login.spec.ts":   "test('login', () => {})",
+		"web/.gitignore":      "*.tmp\n",
+		"web/cache.tmp":       "tmp",
+	})
+	return root
+}
+
+func TestAnalyze(t *testing.T) {
+	repo, err := Analyze(testRepo(t))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	files := strings.Join(repo.Files, " ")
+	for _, ignored := range []string{"bin/shop", "debug.log", "generated/gen.go", "web/cache.tmp"} {
+		if strings.Contains(files, ignored) {
+			t.Errorf("expected %s to be ignored, got %v", ignored, repo.Files)
+		}
+	}
+	if !strings.Contains(files, "keep.log") {
+		t.Errorf("expected negated pattern to keep keep.log, got %v", repo.Files)
+	}
+
+	if len(repo.Modules) != 1 || repo.Modules[0].Path != "example.com/shop" || repo.Modules[0].GoVersion != "1.24" {
+		t.Fatalf("unexpected modules %+v", repo.Modules)
+	}
+	if got := repo.Modules[0].Requires; len(got) != 1 || got[0] != "github.com/google/uuid v1.6.0" {
+		t.Errorf("expected only direct requirements, got %v", got)
+	}
+
+	cart := repo.Package("cart/cart.go")
+	if cart == nil {
+		t.Fatal("expected cart package")
+	}
+	if cart.ImportPath != "example.com/shop/cart" || cart.Name != "cart" {
+		t.Errorf("unexpected package identity %s %s", cart.ImportPath, cart.Name)
+	}
+	var sigs []string
+	for _, s := range cart.Symbols {
+		sigs = append(sigs, s.Signature)
+	}
+	want := []string{
+		"type Cart struct",
+		"func (c *Cart) Add(price money.Cents)",
+		"func Total(c *Cart) money.Cents",
+		"const MaxItems",
+	}
+	if strings.Join(sigs, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected symbols:\n got %q\nwant %q", sigs, want)
+	}
+	if cart.Symbols[0].Doc != "Cart holds the items of an order." {
+		t.Errorf("expected first sentence of doc, got %q", cart.Symbols[0].Doc)
+	}
+	if len(cart.Tests) != 1 || cart.Tests[0] != "TestAdd" {
+		t.Errorf("expected TestAdd, got %v", cart.Tests)
+	}
+	if len(cart.Imports) != 1 || cart.Imports[0] != "example.com/shop/money" {
+		t.Errorf("unexpected imports %v", cart.Imports)
+	}
+
+	money := repo.Package("money/money.go")
+	if money == nil || money.Symbols[1].Signature != "type Formatter interface{ Format }" {
+		t.Errorf("expected interface methods in signature, got %+v", money)
+	}
+	if money != nil && money.Symbols[2].Signature != "type Set[K comparable, V any] map[K]V" {
+		t.Errorf("expected type parameters in signature, got %q", money.Symbols[2].Signature)
+	}
+	if repo.Package("testdata/fixture.go") != nil {
+		t.Error("expected testdata to be skipped")
+	}
+	if broken := repo.Package("broken/broken.go"); broken == nil || len(broken.Files) != 1 {
+		t.Error("expected unparsable file to be listed")
+	}
+}
+
+func TestRender(t *testing.T) {
+	repo, err := Analyze(testRepo(t))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	out := repo.Render(Options{Target: "money/money.go"})
+	for _, want := range []string{
+		"Module example.com/shop (go 1.24)",
+		"Target file: money/money.go (package money, example.com/shop/money)",
+		"- func (c *Cart) Add(price money.Cents) // Add appends an item, e.g. a discount.",
+		"Tests (cart_test.go): TestAdd",
+		"- web/ (2 files) tests: login.spec.ts",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in context:\n%s", want, out)
+		}
+	}
+	// The target comes first, then its importers.
+	if i, j := strings.Index(out, "package money"), strings.Index(out, "package cart"); i < 0 || j < i {
+		t.Errorf("expected target package before its importers:\n%s", out)
+	}
+}
+
+func TestRender_Budget(t *testing.T) {
+	repo, err := Analyze(testRepo(t))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	const budget = 100
+	out := repo.Render(Options{Target: "cart/cart.go", TokenBudget: budget})
+	if EstimateTokens(out) > budget {
+		t.Errorf("context of %d tokens exceeds budget %d:\n%s", EstimateTokens(out), budget, out)
+	}
+	if !strings.Contains(out, "package cart") {
+		t.Errorf("expected target package to be kept:\n%s", out)
+	}
+	if !strings.Contains(out, "omitted to fit the context budget") {
+		t.Errorf("expected omission note:\n%s", out)
+	}
+}
+
+func TestIgnorer(t *testing.T) {
+	ig := &ignorer{}
+	for _, line := range []string{"# comment", "node_modules/", "/dist", "docs/**/*.png", "*.gen.go", "!keep.gen.go"} {
+		ig.add("", line)
+	}
+	ig.add("sub", "local.txt")
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false},
+		{"dist", true, true},
+		{"web/dist", true, false},
+		{"docs/a/b/c.png", false, true},
+		{"docs/c.png", false, true},
+		{"api.gen.go", false, true},
+		{"keep.gen.go", false, false},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+	}
+	for _, tt := range tests {
+		if got := ig.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
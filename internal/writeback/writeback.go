@@ -0,0 +1,176 @@
+// Package writeback delivers generated tests to developers: written into the
+// checkout, as a unified diff or format-patch file, or as a commit on a new
+// local branch. Diffs, patches and branches are built from a commit object
+// created with git plumbing, so the working tree, index and current branch
+// are never touched.
+package writeback
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"localsprite/internal/agent"
+)
+
+// ErrExists is returned by Write when a generated file would replace an
+// existing file and overwriting was not requested.
+var ErrExists = errors.New("file already exists")
+
+// Write writes files, whose paths are relative to root, into the checkout.
+// Existing files are only replaced when overwrite is set; otherwise nothing
+// is written and an error wrapping ErrExists names the first conflict.
+func Write(root string, files []agent.Artifact, overwrite bool) error {
+	if err := checkFiles(files); err != nil {
+		return err
+	}
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f.Path))
+		if err := checkInside(root, p); err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if overwrite {
+			continue
+		}
+		_, err := os.Lstat(p)
+		if err == nil {
+			return fmt.Errorf("%s: %w", f.Path, ErrExists)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return fmt.Errorf("failed to create dir for %s: %w", f.Path, err)
+		}
+		if err := os.WriteFile(p, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// Commit creates a commit whose parent is HEAD and whose tree is HEAD's with
+// files added or replaced, and returns its hash. No branch points to the
+// commit until Branch is called.
+func Commit(ctx context.Context, repo string, files []agent.Artifact, message string) (string, error) {
+	if err := checkFiles(files); err != nil {
+		return "", err
+	}
+
+	// A private index leaves the user's staged changes alone.
+	index, err := os.CreateTemp("", "localsprite-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create index: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if _, err := git(ctx, repo, env, "", "read-tree", "HEAD"); err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		blob, err := git(ctx, repo, env, f.Content, "hash-object", "-w", "--stdin")
+		if err != nil {
+			return "", err
+		}
+		if _, err := git(ctx, repo, env, "", "update-index", "--add", "--cacheinfo", "100644,"+blob+","+f.Path); err != nil {
+			return "", err
+		}
+	}
+	tree, err := git(ctx, repo, env, "", "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return git(ctx, repo, env, message, "commit-tree", tree, "-p", "HEAD")
+}
+
+// Diff returns the unified diff from HEAD to commit. Like FormatPatch, it
+// keeps the default a/ and b/ prefixes whatever diff.noprefix and
+// diff.mnemonicPrefix say, so that git apply takes it as is.
+func Diff(ctx context.Context, repo, commit string) (string, error) {
+	return git(ctx, repo, nil, "", "diff", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "HEAD", commit, "--")
+}
+
+// FormatPatch returns commit as a patch for git am.
+func FormatPatch(ctx context.Context, repo, commit string) (string, error) {
+	return git(ctx, repo, nil, "", "format-patch", "--stdout", "--src-prefix=a/", "--dst-prefix=b/", "-1", commit)
+}
+
+// Branch creates the local branch name at commit without checking it out.
+// It fails if the branch already exists.
+func Branch(ctx context.Context, repo, name, commit string) error {
+	if _, err := git(ctx, repo, nil, "", "check-ref-format", "--branch", name); err != nil {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	_, err := git(ctx, repo, nil, "", "branch", name, commit)
+	return err
+}
+
+// checkInside rejects paths whose existing directories resolve outside root
+// through symlinks.
+func checkInside(root, p string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(p)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(realRoot, realDir); err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return errors.New("path leaves the repository through a symlink")
+	}
+	return nil
+}
+
+// checkFiles requires every file to be named, since unnamed files have no
+// place in the repository until an executor resolves them.
+func checkFiles(files []agent.Artifact) error {
+	if err := agent.ValidateArtifacts(files); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.Path == "" {
+			return errors.New("generated file has no path")
+		}
+	}
+	return nil
+}
+
+// git runs a git command in dir with extra environment and stdin, returning
+// its trimmed output. Diff-like output keeps its trailing newline.
+func git(ctx context.Context, dir string, env []string, stdin string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	switch args[0] {
+	case "diff", "format-patch":
+		return stdout.String(), nil
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
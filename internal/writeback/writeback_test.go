@@ -0,0 +1,139 @@
+package writeback
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"localsprite/internal/agent"
+)
+
+// newRepo creates a git repository with one commit containing a.go.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for _, kv := range []string{
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	} {
+		k, v, _ := strings.Cut(kv, "=")
+		t.Setenv(k, v)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q", "-b", "main"}, {"add", "."}, {"commit", "-q", "-m", "base"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	files := []agent.Artifact{
+		{Path: "a/a_test.go", Content: "package a\n"},
+		{Path: "a/testdata/in.json", Content: "{}\n"},
+	}
+	if err := Write(dir, files, false); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "a", "testdata", "in.json"))
+	if err != nil || string(data) != "{}\n" {
+		t.Errorf("expected nested file to be written, got %q, %v", data, err)
+	}
+
+	files[0].Content = "package a // changed\n"
+	if err := Write(dir, files, false); !errors.Is(err, ErrExists) {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+	if err := Write(dir, files, true); err != nil {
+		t.Fatalf("Write with overwrite failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a", "a_test.go")); string(data) != "package a // changed\n" {
+		t.Errorf("expected file to be replaced, got %q", data)
+	}
+}
+
+func TestWrite_RejectsEscapes(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skip("symlinks not supported")
+	}
+
+	for _, f := range []agent.Artifact{
+		{Path: "../x_test.go", Content: "x"},
+		{Path: "link/x_test.go", Content: "x"},
+		{Content: "unnamed"},
+	} {
+		if err := Write(dir, []agent.Artifact{f}, true); err == nil {
+			t.Errorf("expected %q to be rejected", f.Path)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("expected nothing written outside the repo, got %v", entries)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	dir := newRepo(t)
+	ctx := context.Background()
+	files := []agent.Artifact{{Path: "a_test.go", Content: "package a\n\nimport \"testing\"\n"}}
+
+	commit, err := Commit(ctx, dir, files, "Add generated tests")
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	diff, err := Diff(ctx, dir, commit)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if !strings.Contains(diff, "+++ b/a_test.go") || !strings.Contains(diff, "+import \"testing\"") {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	patch, err := FormatPatch(ctx, dir, commit)
+	if err != nil {
+		t.Fatalf("FormatPatch failed: %v", err)
+	}
+	if !strings.Contains(patch, "Subject: [PATCH] Add generated tests") {
+		t.Errorf("unexpected patch:\n%s", patch)
+	}
+
+	if err := Branch(ctx, dir, "generated-tests", commit); err != nil {
+		t.Fatalf("Branch failed: %v", err)
+	}
+	if err := Branch(ctx, dir, "generated-tests", commit); err == nil {
+		t.Error("expected an error for an existing branch")
+	}
+	if err := Branch(ctx, dir, "bad..name", commit); err == nil {
+		t.Error("expected an error for an invalid branch name")
+	}
+
+	// The working tree, index and current branch are untouched
+	if _, err := os.Stat(filepath.Join(dir, "a_test.go")); !os.IsNotExist(err) {
+		t.Errorf("expected a_test.go not to be written, got %v", err)
+	}
+	status, err := git(ctx, dir, nil, "", "status", "--porcelain")
+	if err != nil || status != "" {
+		t.Errorf("expected a clean working tree, got %q, %v", status, err)
+	}
+	head, _ := git(ctx, dir, nil, "", "symbolic-ref", "--short", "HEAD")
+	if head != "main" {
+		t.Errorf("expected main to stay checked out, got %q", head)
+	}
+}
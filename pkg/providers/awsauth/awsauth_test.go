@@ -0,0 +1,199 @@
+package awsauth
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignV4_GetVanilla uses the get-vanilla case of the AWS SigV4 test suite.
+func TestSignV4_GetVanilla(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	if err := SignV4(req, creds, "service", "us-east-1", now); err != nil {
+		t.Fatalf("SignV4 failed: %v", err)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("unexpected Authorization header\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSignV4_SessionToken(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://bedrock-runtime.us-east-1.amazonaws.com/model/a%3A0/converse", strings.NewReader("{}"))
+	creds := Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}
+
+	if err := SignV4(req, creds, "bedrock", "us-east-1", time.Now()); err != nil {
+		t.Fatalf("SignV4 failed: %v", err)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Error("expected session token header")
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "x-amz-security-token") {
+		t.Errorf("expected session token to be signed, got %s", req.Header.Get("Authorization"))
+	}
+	if got := canonicalURI(req.URL); got != "/model/a%253A0/converse" {
+		t.Errorf("expected double-encoded canonical URI, got %s", got)
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	if got := EscapePath("anthropic.claude-3-5-sonnet-20241022-v2:0"); got != "anthropic.claude-3-5-sonnet-20241022-v2%3A0" {
+		t.Errorf("unexpected escaping: %s", got)
+	}
+}
+
+func TestLoadCredentials_Env(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+
+	creds, err := LoadCredentials(context.Background(), "")
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if creds.AccessKeyID != "AKIDENV" || creds.SessionToken != "token" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+}
+
+func TestLoadCredentials_SharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(path, []byte(`[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+# work account
+[work]
+aws_access_key_id=AKIDWORK
+aws_secret_access_key=work-secret
+`), 0600)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("AWS_PROFILE", "work")
+
+	creds, err := LoadCredentials(context.Background(), "")
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if creds.AccessKeyID != "AKIDWORK" || creds.SecretAccessKey != "work-secret" {
+		t.Errorf("expected work profile, got %+v", creds)
+	}
+
+	if _, err := LoadCredentials(context.Background(), "missing"); err == nil {
+		t.Error("expected error for missing profile")
+	}
+}
+
+// setupConfig points the AWS config file at a new file with content and
+// clears the other credential sources.
+func setupConfig(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", path)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "missing"))
+}
+
+func TestLoadCredentials_ConfigFile(t *testing.T) {
+	setupConfig(t, `[default]
+region = us-east-1
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+[profile  work]
+aws_access_key_id = AKIDWORK
+aws_secret_access_key = work-secret
+
+[profile assumed]
+role_arn = arn:aws:iam::123456789012:role/dev
+source_profile = default
+`)
+
+	creds, err := LoadCredentials(context.Background(), "")
+	if err != nil || creds.AccessKeyID != "AKIDDEFAULT" {
+		t.Errorf("expected default profile, got %+v %v", creds, err)
+	}
+	creds, err = LoadCredentials(context.Background(), "work")
+	if err != nil || creds.AccessKeyID != "AKIDWORK" {
+		t.Errorf("expected work profile, got %+v %v", creds, err)
+	}
+	if _, err := LoadCredentials(context.Background(), "assumed"); err == nil || !strings.Contains(err.Error(), "credential_process") {
+		t.Errorf("expected role_arn to be rejected, got %v", err)
+	}
+}
+
+func TestLoadCredentials_CredentialProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	setupConfig(t, `[profile proc]
+credential_process = printf '{"Version":1,"AccessKeyId":"AKIDPROC","SecretAccessKey":"proc-secret","SessionToken":"proc-token","Expiration":"2999-01-01T00:00:00Z"}'
+
+[profile expired]
+credential_process = printf '{"Version":1,"AccessKeyId":"AKIDPROC","SecretAccessKey":"proc-secret","Expiration":"2000-01-01T00:00:00Z"}'
+
+[profile broken]
+credential_process = echo oops >&2; exit 1
+`)
+
+	creds, err := LoadCredentials(context.Background(), "proc")
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if creds.AccessKeyID != "AKIDPROC" || creds.SecretAccessKey != "proc-secret" || creds.SessionToken != "proc-token" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+	if _, err := LoadCredentials(context.Background(), "expired"); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected expired credentials to be rejected, got %v", err)
+	}
+	if _, err := LoadCredentials(context.Background(), "broken"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("expected the process error output, got %v", err)
+	}
+}
+
+func TestLoadCredentials_SSO(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	setupConfig(t, `[profile sso]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Developer
+`)
+	bin := t.TempDir()
+	script := "#!/bin/sh\n" +
+		`[ "$*" = "configure export-credentials --profile sso --format process" ] || exit 1` + "\n" +
+		`printf '{"Version":1,"AccessKeyId":"AKIDSSO","SecretAccessKey":"sso-secret","SessionToken":"sso-token"}'` + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "aws"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	creds, err := LoadCredentials(context.Background(), "sso")
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if creds.AccessKeyID != "AKIDSSO" || creds.SessionToken != "sso-token" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+}
@@ -0,0 +1,223 @@
+package awsauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Credentials are AWS credentials, optionally with a session token.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// ErrNoCredentials is returned when neither the environment nor the shared
+// config and credentials files provide credentials.
+var ErrNoCredentials = errors.New("no AWS credentials found in environment or shared config files")
+
+// LoadCredentials resolves credentials the way the AWS CLI does, from the
+// first of:
+//
+//   - AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/AWS_SESSION_TOKEN
+//   - the static keys of the named profile (or AWS_PROFILE, or "default")
+//     in the shared credentials file (AWS_SHARED_CREDENTIALS_FILE or
+//     ~/.aws/credentials) or the config file (AWS_CONFIG_FILE or
+//     ~/.aws/config), the former taking precedence
+//   - the profile's credential_process
+//   - for SSO profiles, the token cached by "aws sso login", exported with
+//     "aws configure export-credentials"
+//
+// Profiles that assume a role with role_arn and instance or container
+// credentials are not supported; a credential_process can provide them.
+func LoadCredentials(ctx context.Context, profile string) (Credentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return Credentials{
+			AccessKeyID:     id,
+			SecretAccessKey: secret,
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	settings, err := loadProfile(profile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if settings == nil {
+		return Credentials{}, fmt.Errorf("profile %q not found: %w", profile, ErrNoCredentials)
+	}
+
+	switch {
+	case settings["aws_access_key_id"] != "" && settings["aws_secret_access_key"] != "":
+		return Credentials{
+			AccessKeyID:     settings["aws_access_key_id"],
+			SecretAccessKey: settings["aws_secret_access_key"],
+			SessionToken:    settings["aws_session_token"],
+		}, nil
+	case settings["credential_process"] != "":
+		return processCredentials(shellCommand(ctx, settings["credential_process"]))
+	case settings["sso_session"] != "" || settings["sso_start_url"] != "":
+		cmd := exec.CommandContext(ctx, "aws", "configure", "export-credentials", "--profile", profile, "--format", "process")
+		creds, err := processCredentials(cmd)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("SSO profile %q (run aws sso login first): %w", profile, err)
+		}
+		return creds, nil
+	case settings["role_arn"] != "":
+		return Credentials{}, fmt.Errorf("profile %q assumes a role, which is not supported; set credential_process instead: %w", profile, ErrNoCredentials)
+	}
+	return Credentials{}, fmt.Errorf("profile %q has no static keys, credential_process or SSO settings: %w", profile, ErrNoCredentials)
+}
+
+// loadProfile merges the settings of profile from the config file and the
+// shared credentials file. It returns nil if neither has the profile.
+func loadProfile(profile string) (map[string]string, error) {
+	home, _ := os.UserHomeDir()
+	configPath := os.Getenv("AWS_CONFIG_FILE")
+	if configPath == "" && home != "" {
+		configPath = filepath.Join(home, ".aws", "config")
+	}
+	credsPath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credsPath == "" && home != "" {
+		credsPath = filepath.Join(home, ".aws", "credentials")
+	}
+
+	// The config file prefixes profile sections, except the default one
+	configSection := "profile " + profile
+	if profile == "default" {
+		configSection = "default"
+	}
+
+	var settings map[string]string
+	for _, src := range []struct{ path, section string }{
+		{configPath, configSection},
+		{credsPath, profile},
+	} {
+		if src.path == "" {
+			continue
+		}
+		values, err := readSection(src.path, src.section)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			continue
+		}
+		if settings == nil {
+			settings = map[string]string{}
+		}
+		for k, v := range values {
+			settings[k] = v
+		}
+	}
+	return settings, nil
+}
+
+// readSection reads the settings of one [section] of an INI file. It returns
+// nil if the file or section does not exist.
+func readSection(path, section string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var (
+		values  map[string]string
+		inFound bool
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			inFound = name == section
+			if inFound && values == nil {
+				values = map[string]string{}
+			}
+			continue
+		}
+		if !inFound {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// shellCommand runs a credential_process command line through the shell, as
+// the AWS SDKs do.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// processOutput is the JSON a credential_process prints.
+type processOutput struct {
+	Version         int
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// processCredentials runs cmd and decodes the credentials it prints.
+func processCredentials(cmd *exec.Cmd) (Credentials, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("credential process failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var p processOutput
+	if err := json.Unmarshal(out, &p); err != nil {
+		return Credentials{}, fmt.Errorf("invalid credential process output: %w", err)
+	}
+	switch {
+	case p.Version != 1:
+		return Credentials{}, fmt.Errorf("unsupported credential process output version %d", p.Version)
+	case p.AccessKeyID == "" || p.SecretAccessKey == "":
+		return Credentials{}, errors.New("credential process returned no keys")
+	case !p.Expiration.IsZero() && time.Now().After(p.Expiration):
+		return Credentials{}, fmt.Errorf("credential process returned credentials that expired at %s", p.Expiration.Format(time.RFC3339))
+	}
+	return Credentials{
+		AccessKeyID:     p.AccessKeyID,
+		SecretAccessKey: p.SecretAccessKey,
+		SessionToken:    p.SessionToken,
+	}, nil
+}
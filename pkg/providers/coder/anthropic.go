@@ -1,20 +1,227 @@
 package coder
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"localsprite/internal/agent"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
 )
 
 type AnthropicCoder struct {
 	Model  string
 	APIKey string
+
+	// BaseURL of the Messages API (default: https://api.anthropic.com)
+	BaseURL string
+
+	// MaxTokens caps the length of each response (default: 8192)
+	MaxTokens int
+
+	// SystemPrompt replaces the default coder system prompt when set
+	SystemPrompt string
+
+	// MaxRetries bounds retries of rate-limited (429) and overloaded (529)
+	// requests (default: 5); InitialBackoff is the first retry delay when the
+	// API does not send retry-after (default: 1s)
+	MaxRetries     int
+	InitialBackoff time.Duration
+
+	HTTPClient *http.Client
+
+	mu    sync.Mutex
+	usage TokenUsage
+}
+
+// TokenUsage counts the tokens consumed by a coder across all its calls.
+type TokenUsage struct {
+	InputTokens  int
+	OutputTokens int
 }
 
 func NewAnthropicCoder(model, apiKey string) *AnthropicCoder {
-	return &AnthropicCoder{Model: model, APIKey: apiKey}
+	return &AnthropicCoder{
+		Model:          model,
+		APIKey:         apiKey,
+		BaseURL:        anthropicBaseURL,
+		MaxTokens:      8192,
+		MaxRetries:     5,
+		InitialBackoff: defaultInitialBackoff,
+		HTTPClient:     http.DefaultClient,
+	}
 }
 
-func (a *AnthropicCoder) GenerateCode(plan string, fileContent string) (string, error) {
+func (a *AnthropicCoder) GenerateCode(ctx context.Context, plan *agent.TestPlan, fileContent string) ([]agent.Artifact, error) {
 	fmt.Printf("[Coder] Anthropic (%s) is generating code (High Complexity mode)...\n", a.Model)
-	// Integration with Anthropic API would go here.
-	return "// Generated by Claude\nfunc TestAuth() {}", nil
-}
\ No newline at end of file
+	return a.complete(ctx, codePrompt(plan, fileContent))
+}
+
+// RepairCode asks Claude to fix previously generated code given the output
+// of the failed test run.
+func (a *AnthropicCoder) RepairCode(ctx context.Context, plan *agent.TestPlan, fileContent string, previous []agent.Artifact, feedback string) ([]agent.Artifact, error) {
+	fmt.Printf("[Coder] Anthropic (%s) is repairing code...\n", a.Model)
+	return a.complete(ctx, repairPrompt(plan, fileContent, previous, feedback))
+}
+
+// Usage returns the tokens consumed so far.
+func (a *AnthropicCoder) Usage() TokenUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usage
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// AnthropicError is returned when the Messages API responds with an error.
+type AnthropicError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *AnthropicError) Error() string {
+	return fmt.Sprintf("anthropic API error %d (%s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+func (a *AnthropicCoder) complete(ctx context.Context, prompt string) ([]agent.Artifact, error) {
+	system := a.SystemPrompt
+	if system == "" {
+		system = systemPrompt
+	}
+	body, err := json.Marshal(anthropicRequest{
+		Model:     a.Model,
+		MaxTokens: a.MaxTokens,
+		System:    system,
+		Messages:  []anthropicMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	var parsed anthropicResponse
+	for attempt := 0; ; attempt++ {
+		resp, err := a.send(ctx, body)
+		if err != nil {
+			return nil, err
+		}
+
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			if err := json.Unmarshal(respBody, &parsed); err != nil {
+				return nil, fmt.Errorf("failed to decode response: %w", err)
+			}
+			break
+		}
+
+		apiErr := anthropicError(resp.StatusCode, respBody)
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == 529
+		if !retryable || attempt >= a.MaxRetries {
+			return nil, apiErr
+		}
+
+		delay := retryDelay(attempt, a.InitialBackoff, resp.Header)
+		fmt.Printf("[Coder] Anthropic returned %d, retrying in %s (%d/%d)...\n", resp.StatusCode, delay, attempt+1, a.MaxRetries)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	a.mu.Lock()
+	a.usage.InputTokens += parsed.Usage.InputTokens
+	a.usage.OutputTokens += parsed.Usage.OutputTokens
+	a.mu.Unlock()
+	fmt.Printf("[Coder] Anthropic usage: %d input tokens, %d output tokens\n", parsed.Usage.InputTokens, parsed.Usage.OutputTokens)
+
+	if parsed.StopReason == "max_tokens" {
+		return nil, fmt.Errorf("anthropic %s: response truncated at max_tokens=%d", a.Model, a.MaxTokens)
+	}
+
+	var text strings.Builder
+	for _, block := range parsed.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	files := agent.ParseArtifacts(text.String())
+	if len(files) == 0 {
+		return nil, fmt.Errorf("anthropic %s: response contained no code", a.Model)
+	}
+	return files, nil
+}
+
+func (a *AnthropicCoder) send(ctx context.Context, body []byte) (*http.Response, error) {
+	url := strings.TrimSuffix(a.BaseURL, "/") + "/v1/messages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", a.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	httpClient := a.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call anthropic API: %w", err)
+	}
+	return resp, nil
+}
+
+func anthropicError(status int, body []byte) *AnthropicError {
+	var parsed struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	apiErr := &AnthropicError{StatusCode: status}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error.Message != "" {
+		apiErr.Type = parsed.Error.Type
+		apiErr.Message = parsed.Error.Message
+	} else {
+		apiErr.Type = http.StatusText(status)
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
@@ -0,0 +1,135 @@
+package coder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestAnthropicCoder(url string) *AnthropicCoder {
+	c := NewAnthropicCoder("claude-test", "test-key")
+	c.BaseURL = url
+	c.InitialBackoff = time.Millisecond
+	return c
+}
+
+func TestAnthropicCoder_GenerateCode(t *testing.T) {
+	var got anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("expected /v1/messages, got %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Errorf("missing auth or version headers: %v", r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"content":[{"type":"text","text":"` + "```go\\npackage auth\\n```" + `"}],"stop_reason":"end_turn","usage":{"input_tokens":120,"output_tokens":30}}`))
+	}))
+	defer server.Close()
+
+	c := newTestAnthropicCoder(server.URL)
+	c.MaxTokens = 1024
+	c.SystemPrompt = "custom system"
+
+	files, err := c.GenerateCode(context.Background(), testPlan, "file")
+	if err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Content != "package auth\n" {
+		t.Errorf("unexpected files %+v", files)
+	}
+	if got.MaxTokens != 1024 || got.System != "custom system" || got.Model != "claude-test" {
+		t.Errorf("expected configured request fields, got %+v", got)
+	}
+
+	if _, err := c.GenerateCode(context.Background(), testPlan, "file"); err != nil {
+		t.Fatalf("second GenerateCode failed: %v", err)
+	}
+	if u := c.Usage(); u.InputTokens != 240 || u.OutputTokens != 60 {
+		t.Errorf("expected accumulated usage, got %+v", u)
+	}
+}
+
+func TestAnthropicCoder_RetriesOverloaded(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+		case 2:
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+		default:
+			w.Write([]byte(`{"content":[{"type":"text","text":"package auth"}],"usage":{}}`))
+		}
+	}))
+	defer server.Close()
+
+	if _, err := newTestAnthropicCoder(server.URL).GenerateCode(context.Background(), testPlan, ""); err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestAnthropicCoder_GivesUpAfterMaxRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(529)
+		w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	}))
+	defer server.Close()
+
+	c := newTestAnthropicCoder(server.URL)
+	c.MaxRetries = 2
+	_, err := c.GenerateCode(context.Background(), testPlan, "")
+
+	var apiErr *AnthropicError
+	if !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" {
+		t.Fatalf("expected overloaded AnthropicError, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 1 call plus 2 retries, got %d", calls)
+	}
+}
+
+func TestAnthropicCoder_DoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestAnthropicCoder(server.URL).GenerateCode(context.Background(), testPlan, "")
+	if err == nil || calls != 1 {
+		t.Errorf("expected a single failed call, got %d calls and %v", calls, err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	h := http.Header{}
+	if d := retryDelay(3, time.Second, h); d != 8*time.Second {
+		t.Errorf("expected exponential backoff of 8s, got %s", d)
+	}
+	if d := retryDelay(2, 0, h); d != 4*defaultInitialBackoff {
+		t.Errorf("expected backoff from the default base when none is set, got %s", d)
+	}
+	if d := retryDelay(10, time.Second, h); d != maxBackoff {
+		t.Errorf("expected backoff capped at %s, got %s", maxBackoff, d)
+	}
+	h.Set("Retry-After", "2")
+	if d := retryDelay(3, time.Second, h); d != 2*time.Second {
+		t.Errorf("expected retry-after of 2s, got %s", d)
+	}
+}
@@ -1,20 +1,261 @@
 package coder
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"localsprite/internal/agent"
+	"localsprite/pkg/providers/awsauth"
 )
 
 type BedrockCoder struct {
 	Model  string
 	Region string
+
+	// Endpoint overrides the bedrock-runtime endpoint for the region, e.g.
+	// for VPC endpoints or local testing
+	Endpoint string
+
+	// Profile selects the shared credentials profile when credentials are not
+	// set in the environment (default: AWS_PROFILE or "default")
+	Profile string
+
+	// MaxTokens caps the length of each response (default: 8192)
+	MaxTokens int
+
+	// SystemPrompt replaces the default coder system prompt when set
+	SystemPrompt string
+
+	// MaxRetries bounds retries of throttled or unavailable requests
+	// (default: 5); InitialBackoff is the first retry delay (default: 1s)
+	MaxRetries     int
+	InitialBackoff time.Duration
+
+	HTTPClient *http.Client
+
+	mu    sync.Mutex
+	usage TokenUsage
 }
 
 func NewBedrockCoder(model, region string) *BedrockCoder {
-	return &BedrockCoder{Model: model, Region: region}
+	return &BedrockCoder{
+		Model:          model,
+		Region:         region,
+		MaxTokens:      8192,
+		MaxRetries:     5,
+		InitialBackoff: defaultInitialBackoff,
+		HTTPClient:     http.DefaultClient,
+	}
 }
 
-func (b *BedrockCoder) GenerateCode(plan string, fileContent string) (string, error) {
+func (b *BedrockCoder) GenerateCode(ctx context.Context, plan *agent.TestPlan, fileContent string) ([]agent.Artifact, error) {
 	fmt.Printf("[Coder] AWS Bedrock (%s in %s) is generating code based on plan...\n", b.Model, b.Region)
-	// Integration with AWS SDK v2 would go here.
-	return "// Generated by Bedrock\nfunc TestAuth() {}", nil
-}
\ No newline at end of file
+	return b.complete(ctx, codePrompt(plan, fileContent))
+}
+
+// RepairCode asks the model to fix previously generated code given the
+// output of the failed test run.
+func (b *BedrockCoder) RepairCode(ctx context.Context, plan *agent.TestPlan, fileContent string, previous []agent.Artifact, feedback string) ([]agent.Artifact, error) {
+	fmt.Printf("[Coder] AWS Bedrock (%s in %s) is repairing code...\n", b.Model, b.Region)
+	return b.complete(ctx, repairPrompt(plan, fileContent, previous, feedback))
+}
+
+// Usage returns the tokens consumed so far.
+func (b *BedrockCoder) Usage() TokenUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.usage
+}
+
+type converseContent struct {
+	Text string `json:"text"`
+}
+
+type converseMessage struct {
+	Role    string            `json:"role"`
+	Content []converseContent `json:"content"`
+}
+
+type converseRequest struct {
+	Messages        []converseMessage `json:"messages"`
+	System          []converseContent `json:"system,omitempty"`
+	InferenceConfig struct {
+		MaxTokens int `json:"maxTokens,omitempty"`
+	} `json:"inferenceConfig"`
+}
+
+type converseResponse struct {
+	Output struct {
+		Message converseMessage `json:"message"`
+	} `json:"output"`
+	StopReason string `json:"stopReason"`
+	Usage      struct {
+		InputTokens  int `json:"inputTokens"`
+		OutputTokens int `json:"outputTokens"`
+	} `json:"usage"`
+}
+
+// BedrockError is returned when the Bedrock runtime responds with an error.
+type BedrockError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *BedrockError) Error() string {
+	msg := fmt.Sprintf("bedrock error %d (%s): %s", e.StatusCode, e.Type, e.Message)
+	switch e.Type {
+	case "AccessDeniedException":
+		msg += " (check IAM permissions for bedrock:InvokeModel and that model access is enabled in the Bedrock console for this region)"
+	case "ResourceNotFoundException":
+		msg += " (check the model ID and that it is available in this region)"
+	}
+	return msg
+}
+
+// retryable reports whether the request may succeed if sent again.
+func (e *BedrockError) retryable() bool {
+	switch e.Type {
+	case "ThrottlingException", "ServiceUnavailableException", "ModelNotReadyException":
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+func (b *BedrockCoder) endpoint() string {
+	if b.Endpoint != "" {
+		return strings.TrimSuffix(b.Endpoint, "/")
+	}
+	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", b.Region)
+}
+
+func (b *BedrockCoder) complete(ctx context.Context, prompt string) ([]agent.Artifact, error) {
+	creds, err := awsauth.LoadCredentials(ctx, b.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: %w", err)
+	}
+
+	system := b.SystemPrompt
+	if system == "" {
+		system = systemPrompt
+	}
+	reqBody := converseRequest{
+		Messages: []converseMessage{{Role: "user", Content: []converseContent{{Text: prompt}}}},
+		System:   []converseContent{{Text: system}},
+	}
+	reqBody.InferenceConfig.MaxTokens = b.MaxTokens
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	url := b.endpoint() + "/model/" + awsauth.EscapePath(b.Model) + "/converse"
+
+	var parsed converseResponse
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		if err := awsauth.SignV4(req, creds, "bedrock", b.Region, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+
+		httpClient := b.HTTPClient
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call bedrock: %w", err)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			if err := json.Unmarshal(respBody, &parsed); err != nil {
+				return nil, fmt.Errorf("failed to decode response: %w", err)
+			}
+			break
+		}
+
+		apiErr := bedrockError(resp, respBody)
+		if !apiErr.retryable() || attempt >= b.MaxRetries {
+			return nil, apiErr
+		}
+
+		delay := retryDelay(attempt, b.InitialBackoff, resp.Header)
+		fmt.Printf("[Coder] Bedrock returned %s, retrying in %s (%d/%d)...\n", apiErr.Type, delay, attempt+1, b.MaxRetries)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	b.mu.Lock()
+	b.usage.InputTokens += parsed.Usage.InputTokens
+	b.usage.OutputTokens += parsed.Usage.OutputTokens
+	b.mu.Unlock()
+	fmt.Printf("[Coder] Bedrock usage: %d input tokens, %d output tokens\n", parsed.Usage.InputTokens, parsed.Usage.OutputTokens)
+
+	if parsed.StopReason == "max_tokens" {
+		return nil, fmt.Errorf("bedrock %s: response truncated at maxTokens=%d", b.Model, b.MaxTokens)
+	}
+
+	var text strings.Builder
+	for _, c := range parsed.Output.Message.Content {
+		text.WriteString(c.Text)
+	}
+	files := agent.ParseArtifacts(text.String())
+	if len(files) == 0 {
+		return nil, fmt.Errorf("bedrock %s: response contained no code", b.Model)
+	}
+	return files, nil
+}
+
+// bedrockError builds an error from the x-amzn-ErrorType header (e.g.
+// "ThrottlingException:http://internal.amazon.com/coral/...") and the JSON
+// message body.
+func bedrockError(resp *http.Response, body []byte) *BedrockError {
+	apiErr := &BedrockError{StatusCode: resp.StatusCode}
+
+	errType := resp.Header.Get("X-Amzn-Errortype")
+	errType, _, _ = strings.Cut(errType, ":")
+	apiErr.Type = errType
+
+	var parsed struct {
+		Message  string `json:"message"`
+		MessageU string `json:"Message"`
+		Type     string `json:"__type"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		apiErr.Message = parsed.Message
+		if apiErr.Message == "" {
+			apiErr.Message = parsed.MessageU
+		}
+		if apiErr.Type == "" && parsed.Type != "" {
+			_, apiErr.Type, _ = strings.Cut(parsed.Type, "#")
+			if apiErr.Type == "" {
+				apiErr.Type = parsed.Type
+			}
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	if apiErr.Type == "" {
+		apiErr.Type = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
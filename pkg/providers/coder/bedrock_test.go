@@ -0,0 +1,109 @@
+package coder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBedrockCoder(t *testing.T, url string) *BedrockCoder {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	c := NewBedrockCoder("anthropic.claude-3-5-sonnet-20241022-v2:0", "us-east-1")
+	c.Endpoint = url
+	c.InitialBackoff = time.Millisecond
+	return c
+}
+
+func TestBedrockCoder_GenerateCode(t *testing.T) {
+	var got converseRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/model/anthropic.claude-3-5-sonnet-20241022-v2%3A0/converse" {
+			t.Errorf("unexpected path %s", r.URL.EscapedPath())
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(auth, "/us-east-1/bedrock/aws4_request") {
+			t.Errorf("expected SigV4 authorization for bedrock, got %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"` + "```go\\npackage auth\\n```" + `"}]}},"stopReason":"end_turn","usage":{"inputTokens":50,"outputTokens":10}}`))
+	}))
+	defer server.Close()
+
+	c := newTestBedrockCoder(t, server.URL)
+	c.MaxTokens = 2048
+	files, err := c.GenerateCode(context.Background(), testPlan, "file")
+	if err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Content != "package auth\n" {
+		t.Errorf("unexpected files %+v", files)
+	}
+	if got.InferenceConfig.MaxTokens != 2048 || len(got.Messages) != 1 || !strings.Contains(got.Messages[0].Content[0].Text, "plan") {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if u := c.Usage(); u.InputTokens != 50 || u.OutputTokens != 10 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+}
+
+func TestBedrockCoder_RetriesThrottling(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("X-Amzn-Errortype", "ThrottlingException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message":"Too many requests, please wait before trying again."}`))
+			return
+		}
+		w.Write([]byte(`{"output":{"message":{"content":[{"text":"package auth"}]}},"usage":{}}`))
+	}))
+	defer server.Close()
+
+	if _, err := newTestBedrockCoder(t, server.URL).GenerateCode(context.Background(), testPlan, ""); err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected a retry after throttling, got %d calls", calls)
+	}
+}
+
+func TestBedrockCoder_ModelAccessDenied(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Errortype", "AccessDeniedException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"You don't have access to the model with the specified model ID."}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestBedrockCoder(t, server.URL).GenerateCode(context.Background(), testPlan, "")
+
+	var apiErr *BedrockError
+	if !errors.As(err, &apiErr) || apiErr.Type != "AccessDeniedException" {
+		t.Fatalf("expected AccessDeniedException, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "model access") {
+		t.Errorf("expected model access hint, got %v", err)
+	}
+}
+
+func TestBedrockCoder_MissingCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/missing")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/missing")
+
+	c := NewBedrockCoder("model", "us-east-1")
+	c.Endpoint = "http://127.0.0.1:0"
+	if _, err := c.GenerateCode(context.Background(), testPlan, ""); err == nil || !strings.Contains(err.Error(), "credentials") {
+		t.Errorf("expected missing credentials error, got %v", err)
+	}
+}
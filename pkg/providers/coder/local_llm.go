@@ -1,20 +1,66 @@
 package coder
 
 import (
+	"context"
 	"fmt"
+
+	"localsprite/internal/agent"
+	"localsprite/pkg/providers/openaicompat"
 )
 
 type LocalLLMCoder struct {
 	Endpoint string
 	Model    string
+
+	// SystemPrompt replaces the default coder system prompt when set
+	SystemPrompt string
+
+	// Client talks to the OpenAI-compatible endpoint (e.g. Ollama's /v1)
+	Client *openaicompat.Client
 }
 
 func NewLocalLLMCoder(endpoint, model string) *LocalLLMCoder {
-	return &LocalLLMCoder{Endpoint: endpoint, Model: model}
+	return &LocalLLMCoder{
+		Endpoint: endpoint,
+		Model:    model,
+		Client:   openaicompat.NewClient(endpoint),
+	}
 }
 
-func (l *LocalLLMCoder) GenerateCode(plan string, fileContent string) (string, error) {
+func (l *LocalLLMCoder) GenerateCode(ctx context.Context, plan *agent.TestPlan, fileContent string) ([]agent.Artifact, error) {
 	fmt.Printf("[Coder] Local LLM (%s at %s) is generating code (Low Cost mode)...\n", l.Model, l.Endpoint)
-	// Integration with OpenAI-compatible endpoint would go here.
-	return "// Generated by Local LLM\nfunc TestAuth() {}", nil
-}
\ No newline at end of file
+	return l.complete(ctx, codePrompt(plan, fileContent))
+}
+
+// RepairCode asks the model to fix previously generated code given the
+// output of the failed test run.
+func (l *LocalLLMCoder) RepairCode(ctx context.Context, plan *agent.TestPlan, fileContent string, previous []agent.Artifact, feedback string) ([]agent.Artifact, error) {
+	fmt.Printf("[Coder] Local LLM (%s at %s) is repairing code...\n", l.Model, l.Endpoint)
+	return l.complete(ctx, repairPrompt(plan, fileContent, previous, feedback))
+}
+
+func (l *LocalLLMCoder) complete(ctx context.Context, prompt string) ([]agent.Artifact, error) {
+	system := l.SystemPrompt
+	if system == "" {
+		system = systemPrompt
+	}
+	resp, err := l.Client.ChatCompletion(ctx, openaicompat.Request{
+		Model: l.Model,
+		Messages: []openaicompat.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local LLM %s: %w", l.Model, err)
+	}
+	if resp.FinishReason == "length" {
+		return nil, fmt.Errorf("local LLM %s: response truncated at the model's output limit", l.Model)
+	}
+
+	files := agent.ParseArtifacts(resp.Content)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("local LLM %s: response contained no code", l.Model)
+	}
+	return files, nil
+}
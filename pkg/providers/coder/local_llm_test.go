@@ -0,0 +1,110 @@
+package coder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"localsprite/internal/agent"
+	"localsprite/pkg/providers/openaicompat"
+)
+
+var testPlan = &agent.TestPlan{
+	Summary:   "cover login",
+	Scenarios: []agent.Scenario{{Name: "bad password", Function: "Login", Assertions: []string{"returns ErrUnauthorized"}}},
+}
+
+func TestLocalLLMCoder_GenerateCode(t *testing.T) {
+	var got openaicompat.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("expected /v1/chat/completions, got %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Here you go:\n` +
+			"```go\\npackage auth\\n\\nfunc TestLogin(t *testing.T) {}\\n```" +
+			`\nGood luck!"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	c := NewLocalLLMCoder(server.URL+"/v1", "qwen2.5-coder:7b")
+	files, err := c.GenerateCode(context.Background(), testPlan, "package auth")
+	if err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+
+	if len(files) != 1 || files[0].Content != "package auth\n\nfunc TestLogin(t *testing.T) {}\n" {
+		t.Errorf("expected extracted code block, got %+v", files)
+	}
+	if got.Model != "qwen2.5-coder:7b" {
+		t.Errorf("expected model to be sent, got %s", got.Model)
+	}
+	if len(got.Messages) != 2 || !strings.Contains(got.Messages[1].Content, "cover login") || !strings.Contains(got.Messages[1].Content, "returns ErrUnauthorized") || !strings.Contains(got.Messages[1].Content, "package auth") {
+		t.Errorf("expected plan and file content in prompt, got %+v", got.Messages)
+	}
+	if got.Messages[0].Content != systemPrompt {
+		t.Errorf("expected the default system prompt, got %q", got.Messages[0].Content)
+	}
+
+	c.SystemPrompt = "custom system"
+	if _, err := c.GenerateCode(context.Background(), testPlan, "package auth"); err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if got.Messages[0].Content != "custom system" {
+		t.Errorf("expected the system prompt to be replaced, got %q", got.Messages[0].Content)
+	}
+}
+
+func TestLocalLLMCoder_RepairCodeIncludesFeedback(t *testing.T) {
+	var got openaicompat.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices":[{"message":{"content":"package auth"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	c := NewLocalLLMCoder(server.URL, "qwen")
+	previous := []agent.Artifact{{Path: "auth/auth_test.go", Content: "old code"}}
+	if _, err := c.RepairCode(context.Background(), testPlan, "", previous, "undefined: Login"); err != nil {
+		t.Fatalf("RepairCode failed: %v", err)
+	}
+	prompt := got.Messages[1].Content
+	if !strings.Contains(prompt, "File: auth/auth_test.go\n```\nold code") || !strings.Contains(prompt, "undefined: Login") {
+		t.Errorf("expected previous code and feedback in prompt, got %q", prompt)
+	}
+}
+
+func TestLocalLLMCoder_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model \"qwen\" not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewLocalLLMCoder(server.URL, "qwen").GenerateCode(context.Background(), testPlan, "")
+
+	var apiErr *openaicompat.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 APIError, got %v", err)
+	}
+	if !strings.Contains(apiErr.Message, "try pulling it first") {
+		t.Errorf("expected model error message, got %q", apiErr.Message)
+	}
+}
+
+func TestLocalLLMCoder_Truncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"` + "```go\\npackage" + `"},"finish_reason":"length"}]}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewLocalLLMCoder(server.URL, "qwen").GenerateCode(context.Background(), testPlan, ""); err == nil {
+		t.Error("expected error for truncated response")
+	}
+}
@@ -0,0 +1,34 @@
+package coder
+
+import (
+	"fmt"
+	"strings"
+
+	"localsprite/internal/agent"
+)
+
+// systemPrompt is sent to every coder model unless the coder's
+// system_prompt param replaces it.
+const systemPrompt = `You are an expert software engineer writing automated tests.
+Respond with one fenced code block per file and nothing else. Put "File: <path>" on the line before each block, with the path relative to the repository root; test files go next to the code they test, and fixtures, page objects or testdata inputs may be added as separate files.`
+
+// codePrompt builds the user prompt for generating tests from a plan.
+func codePrompt(plan *agent.TestPlan, fileContent string) string {
+	var b strings.Builder
+	b.WriteString("Write tests that implement the following test plan.\n\n")
+	fmt.Fprintf(&b, "Test plan:\n%s\n", plan)
+	if fileContent != "" {
+		fmt.Fprintf(&b, "\nCode under test:\n```\n%s\n```\n", fileContent)
+	}
+	return b.String()
+}
+
+// repairPrompt builds the user prompt for fixing previously generated tests.
+func repairPrompt(plan *agent.TestPlan, fileContent string, previous []agent.Artifact, feedback string) string {
+	var b strings.Builder
+	b.WriteString(codePrompt(plan, fileContent))
+	fmt.Fprintf(&b, "\nThe previous attempt below did not pass. Fix it so that it compiles and the tests pass, and return every file again, complete and corrected.\n")
+	fmt.Fprintf(&b, "\nPrevious attempt:\n%s", agent.FormatArtifacts(previous))
+	fmt.Fprintf(&b, "\nTest run output:\n```\n%s\n```\n", feedback)
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package coder
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultInitialBackoff is the first retry delay of coders that do not
+	// set one.
+	defaultInitialBackoff = time.Second
+
+	// maxBackoff caps the delay between retries of throttled requests.
+	maxBackoff = 60 * time.Second
+)
+
+// retryDelay returns how long to wait before retry number attempt (starting
+// at 0). A Retry-After header, in seconds or as an HTTP date, takes
+// precedence over exponential backoff from base, or from
+// defaultInitialBackoff if base is not positive.
+func retryDelay(attempt int, base time.Duration, header http.Header) time.Duration {
+	if ra := header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.ParseFloat(ra, 64); err == nil && secs >= 0 {
+			return min(time.Duration(secs*float64(time.Second)), maxBackoff)
+		}
+		if t, err := http.ParseTime(ra); err == nil {
+			return min(max(time.Until(t), 0), maxBackoff)
+		}
+	}
+
+	if base <= 0 {
+		base = defaultInitialBackoff
+	}
+	d := base << attempt
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -1,5 +1,16 @@
 package executor
 
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
 // ExecutorConfig holds configuration for test execution
 type ExecutorConfig struct {
 	// Host is the Docker host (e.g., "ssh://imperial-construct" or empty for local)
@@ -20,8 +31,88 @@ type ExecutorConfig struct {
 
 	// Timeout in seconds for test execution (default: 300)
 	Timeout int
+
+	// ResultFormat selects how test output is parsed into per-test results:
+	// ResultFormatText (default), ResultFormatGoJSON or ResultFormatJUnit.
+	ResultFormat string
+
+	// ResultPath is the file or directory, relative to WorkDir unless
+	// absolute, that JUnit XML reports are copied from (default: results)
+	ResultPath string
+
+	// Env holds extra environment variables for the container ("KEY=value")
+	Env []string
+
+	// Coverage runs go test commands with -coverprofile and copies the
+	// profile out of the container into ExecutionResult.Coverage. A
+	// -coverprofile already in Command is used as is.
+	Coverage bool
+
+	// Repeat runs the tests this many times to detect flaky tests: go test
+	// commands get -count, other runners are run again in new containers.
+	// Values below 2 run the tests once.
+	Repeat int
+
+	// Shuffle randomizes the order of go tests with -shuffle=on.
+	Shuffle bool
+
+	// Memory limits the memory of the container in bytes, swap included
+	// (default: unlimited)
+	Memory int64
+
+	// CPUs limits the container to this many CPUs, e.g. 1.5 (default:
+	// unlimited)
+	CPUs float64
+
+	// PidsLimit caps the number of processes in the container, which stops
+	// fork bombs (default: unlimited)
+	PidsLimit int64
+
+	// NetworkMode is the Docker network mode of the container (default:
+	// none). Tests that download dependencies or reach a running app need
+	// "bridge" or a named network.
+	NetworkMode string
+
+	// ReadOnly mounts the root filesystem of the container read-only.
+	// WorkDir and /tmp stay writable as volumes, and GOCACHE is moved to
+	// /tmp.
+	ReadOnly bool
+
+	// User runs the test command as this user, as "name", "uid" or
+	// "uid:gid" (default: the user of the image)
+	User string
+
+	// CapDrop lists the Linux capabilities to drop, e.g. ["ALL"].
+	// DAC_OVERRIDE is always dropped when the project source is included.
+	CapDrop []string
+
+	// SeccompProfile is the path of a seccomp profile JSON file, read on the
+	// machine running localsprite, or "unconfined" (default: the daemon's
+	// default profile)
+	SeccompProfile string
 }
 
+const (
+	// ResultFormatText parses the plain output of `go test -v`.
+	ResultFormatText = "text"
+
+	// ResultFormatGoJSON runs go test with -json and parses the event stream.
+	ResultFormatGoJSON = "go-json"
+
+	// ResultFormatJUnit copies JUnit XML reports out of the container after
+	// the run, as written by the Playwright and Cypress junit reporters.
+	ResultFormatJUnit = "junit"
+)
+
+// defaultCoverProfile is where the coverage profile is written in the
+// container when Command does not name one. It is outside WorkDir so that
+// it never ends up among the project files.
+const defaultCoverProfile = "/tmp/localsprite.coverprofile"
+
+// DefaultNetworkMode isolates the container from the network unless a
+// profile opts in.
+const DefaultNetworkMode = "none"
+
 // DefaultGoConfig returns default configuration for Go tests
 func DefaultGoConfig() ExecutorConfig {
 	return ExecutorConfig{
@@ -37,10 +128,14 @@ func DefaultGoConfig() ExecutorConfig {
 func DefaultPlaywrightConfig() ExecutorConfig {
 	return ExecutorConfig{
 		Image:           "mcr.microsoft.com/playwright:v1.40.0-jammy",
-		Command:         []string{"npx", "playwright", "test"},
+		Command:         []string{"npx", "playwright", "test", "--reporter=junit"},
 		WorkDir:         "/app",
 		TestFilePattern: "generated.spec.ts",
 		Timeout:         600,
+		ResultFormat:    ResultFormatJUnit,
+		ResultPath:      "results",
+		Env:             []string{"PLAYWRIGHT_JUNIT_OUTPUT_NAME=results/junit.xml"},
+		NetworkMode:     "bridge",
 	}
 }
 
@@ -48,9 +143,214 @@ func DefaultPlaywrightConfig() ExecutorConfig {
 func DefaultCypressConfig() ExecutorConfig {
 	return ExecutorConfig{
 		Image:           "cypress/included:13.6.0",
-		Command:         []string{"cypress", "run"},
+		Command:         []string{"cypress", "run", "--reporter", "junit", "--reporter-options", "mochaFile=results/junit-[hash].xml"},
 		WorkDir:         "/e2e",
 		TestFilePattern: "generated.cy.ts",
 		Timeout:         600,
+		ResultFormat:    ResultFormatJUnit,
+		ResultPath:      "results",
+		NetworkMode:     "bridge",
+	}
+}
+
+// ConfigFromParams builds an ExecutorConfig from the string params of a
+// profile's executor section. Unset params are left zero so the executor
+// constructors can apply their own defaults.
+func ConfigFromParams(params map[string]string) (ExecutorConfig, error) {
+	cfg := ExecutorConfig{
+		Host:            params["host"],
+		Image:           params["image"],
+		WorkDir:         params["workdir"],
+		TestFilePattern: params["test_file_pattern"],
+		ResultFormat:    params["result_format"],
+		ResultPath:      params["result_path"],
+		NetworkMode:     params["network"],
+		User:            params["user"],
+		SeccompProfile:  params["seccomp"],
+		Command:         splitList(params["command"]),
+		CapDrop:         splitList(params["cap_drop"]),
+	}
+
+	switch cfg.ResultFormat {
+	case "", ResultFormatText, ResultFormatGoJSON, ResultFormatJUnit:
+	default:
+		return cfg, fmt.Errorf("unknown executor result_format %q", cfg.ResultFormat)
+	}
+
+	if env := params["env"]; env != "" {
+		for _, kv := range strings.Split(env, ",") {
+			kv = strings.TrimSpace(kv)
+			if kv == "" {
+				continue
+			}
+			if !strings.Contains(kv, "=") {
+				return cfg, fmt.Errorf("invalid executor env entry %q: expected KEY=value", kv)
+			}
+			cfg.Env = append(cfg.Env, kv)
+		}
+	}
+
+	for name, dst := range map[string]*bool{"coverage": &cfg.Coverage, "shuffle": &cfg.Shuffle, "read_only": &cfg.ReadOnly} {
+		if v := params[name]; v != "" {
+			on, err := strconv.ParseBool(v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid executor %s %q: must be true or false", name, v)
+			}
+			*dst = on
+		}
+	}
+
+	if repeat := params["repeat"]; repeat != "" {
+		n, err := strconv.Atoi(repeat)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid executor repeat %q: must be a non-negative number of runs", repeat)
+		}
+		cfg.Repeat = n
+	}
+
+	if memory := params["memory"]; memory != "" {
+		n, err := units.RAMInBytes(memory)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid executor memory %q: must be a size such as 512m or 2g", memory)
+		}
+		cfg.Memory = n
+	}
+
+	if cpus := params["cpus"]; cpus != "" {
+		n, err := strconv.ParseFloat(cpus, 64)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid executor cpus %q: must be a non-negative number of CPUs", cpus)
+		}
+		cfg.CPUs = n
+	}
+
+	if pids := params["pids_limit"]; pids != "" {
+		n, err := strconv.ParseInt(pids, 10, 64)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid executor pids_limit %q: must be a non-negative number of processes", pids)
+		}
+		cfg.PidsLimit = n
+	}
+
+	if timeout := params["timeout"]; timeout != "" {
+		secs, err := strconv.Atoi(timeout)
+		if err != nil || secs < 0 {
+			return cfg, fmt.Errorf("invalid executor timeout %q: must be a non-negative number of seconds", timeout)
+		}
+		cfg.Timeout = secs
+	}
+
+	return cfg, nil
+}
+
+// splitList splits a comma-separated param, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// testCommand returns the command to run in the container. go test
+// invocations get -json when the Go JSON result format is selected,
+// -coverprofile when coverage is enabled, and -count and -shuffle to detect
+// flaky tests.
+func (c ExecutorConfig) testCommand() []string {
+	if !c.isGoTest() {
+		return c.Command
+	}
+
+	var flags []string
+	if c.ResultFormat == ResultFormatGoJSON && !c.hasFlag("json") {
+		flags = append(flags, "-json")
+	}
+	if c.Coverage && !c.hasFlag("coverprofile") {
+		flags = append(flags, "-coverprofile="+defaultCoverProfile)
+	}
+	if c.Repeat > 1 && !c.hasFlag("count") {
+		flags = append(flags, "-count="+strconv.Itoa(c.Repeat))
+	}
+	if c.Shuffle && !c.hasFlag("shuffle") {
+		flags = append(flags, "-shuffle=on")
+	}
+	if len(flags) == 0 {
+		return c.Command
+	}
+
+	cmd := make([]string, 0, len(c.Command)+len(flags))
+	cmd = append(cmd, "go", "test")
+	cmd = append(cmd, flags...)
+	return append(cmd, c.Command[2:]...)
+}
+
+// withCommand returns a copy of the config that runs command once instead,
+// with its output parsed as plain text and no coverage collected. The
+// timeout is raised to at least timeout.
+func (c ExecutorConfig) withCommand(command []string, timeout time.Duration) ExecutorConfig {
+	if secs := int(math.Ceil(timeout.Seconds())); secs > c.Timeout {
+		c.Timeout = secs
+	}
+	c.Command = command
+	c.ResultFormat = ResultFormatText
+	c.Coverage = false
+	c.Repeat = 0
+	c.Shuffle = false
+	return c
+}
+
+// coverProfile returns the absolute in-container path of the coverage
+// profile, or "" when coverage is not measured.
+func (c ExecutorConfig) coverProfile() string {
+	if !c.Coverage || !c.isGoTest() {
+		return ""
+	}
+	p := defaultCoverProfile
+	args := c.Command[2:]
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "coverprofile" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		p = value
+	}
+	if path.IsAbs(p) {
+		return p
+	}
+	return path.Join(c.WorkDir, p)
+}
+
+func (c ExecutorConfig) isGoTest() bool {
+	return len(c.Command) >= 2 && c.Command[0] == "go" && c.Command[1] == "test"
+}
+
+// hasFlag reports whether the go test arguments of Command set the flag
+// name, in its -name, --name or -name=value forms.
+func (c ExecutorConfig) hasFlag(name string) bool {
+	for _, arg := range c.Command[2:] {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if flag, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "="); flag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resultPath returns the absolute in-container path of the JUnit reports.
+func (c ExecutorConfig) resultPath() string {
+	p := c.ResultPath
+	if p == "" {
+		p = "results"
+	}
+	if path.IsAbs(p) {
+		return p
 	}
+	return path.Join(c.WorkDir, p)
 }
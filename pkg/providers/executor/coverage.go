@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/client"
+
+	"localsprite/internal/agent"
+)
+
+// coverLine matches a block of a coverage profile:
+// "example.com/shop/cart/cart.go:12.30,14.2 2 1".
+var coverLine = regexp.MustCompile(`^(.+):(\d+)\.(\d+),(\d+)\.(\d+) (\d+) (\d+)$`)
+
+// ParseCoverProfile parses a coverage profile as written by go test
+// -coverprofile. Blocks reported more than once, as happens with -coverpkg
+// when several test binaries cover the same package, are merged by adding
+// their counts.
+func ParseCoverProfile(data []byte) ([]agent.CoverBlock, error) {
+	var (
+		blocks []agent.CoverBlock
+		seen   = map[agent.CoverBlock]int{}
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		m := coverLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("failed to parse coverage profile: line %d: unexpected %q", n, line)
+		}
+
+		b := agent.CoverBlock{File: m[1]}
+		for i, field := range []*int{&b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol, &b.Statements} {
+			*field, _ = strconv.Atoi(m[i+2])
+		}
+		count, _ := strconv.Atoi(m[7])
+		if i, ok := seen[b]; ok {
+			blocks[i].Count += count
+			continue
+		}
+		seen[b] = len(blocks)
+		b.Count = count
+		blocks = append(blocks, b)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read coverage profile: %w", err)
+	}
+	return blocks, nil
+}
+
+// copyCoverProfile copies the coverage profile at srcPath out of the
+// container.
+func copyCoverProfile(ctx context.Context, cli *client.Client, containerID, srcPath string) ([]byte, error) {
+	rc, _, err := cli.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container: %w", srcPath, err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s is not a file", srcPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read coverage archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(tr, maxReportSize)); err != nil {
+			return nil, fmt.Errorf("failed to read coverage profile: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+}
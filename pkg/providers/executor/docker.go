@@ -0,0 +1,226 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"localsprite/internal/agent"
+)
+
+// runTests runs the tests in a container, or cfg.Repeat times to detect
+// flaky tests. go test repeats the tests itself with -count; other runners
+// get a new container per run. Repeated outcomes are merged per test with
+// agent.ClassifyRuns. The result of the first failing run, or of the first
+// run if all passed, describes the exit code and output.
+func runTests(ctx context.Context, cli *client.Client, cfg ExecutorConfig, workspaceDir, sourceDir string, mode workspaceMode) (*agent.ExecutionResult, error) {
+	if cfg.Repeat < 2 || cfg.isGoTest() {
+		result, err := runContainer(ctx, cli, cfg, workspaceDir, sourceDir, mode)
+		if err != nil || cfg.Repeat < 2 {
+			return result, err
+		}
+		result.Tests = agent.ClassifyRuns(result.Tests)
+		result.Runs = cfg.Repeat
+		return result, nil
+	}
+
+	var (
+		result *agent.ExecutionResult
+		tests  []agent.TestOutcome
+	)
+	for run := 1; run <= cfg.Repeat; run++ {
+		fmt.Printf("[Executor] Run %d/%d\n", run, cfg.Repeat)
+		r, err := runContainer(ctx, cli, cfg, workspaceDir, sourceDir, mode)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, r.Tests...)
+		switch {
+		case result == nil:
+			result = r
+		case result.Passed() && !r.Passed():
+			r.Duration += result.Duration
+			result = r
+		default:
+			result.Duration += r.Duration
+		}
+	}
+	result.Tests = agent.ClassifyRuns(tests)
+	result.Runs = cfg.Repeat
+	return result, nil
+}
+
+// runContainer pulls the configured image, runs the test command with the
+// contents of workspaceDir, on top of the project source in sourceDir if
+// set, at the working directory, and collects the
+// outcome. A non-zero exit code or hitting cfg.Timeout is reported in the
+// result, not as an error; cancellation of parent is returned as an error
+// after the container has been killed and removed.
+func runContainer(parent context.Context, cli *client.Client, cfg ExecutorConfig, workspaceDir, sourceDir string, mode workspaceMode) (*agent.ExecutionResult, error) {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	// Ensure the image is available
+	fmt.Printf("[Executor] Pulling image %s (if needed)...\n", cfg.Image)
+	pullOut, err := cli.ImagePull(ctx, cfg.Image, image.PullOptions{})
+	if err != nil {
+		// Image might already exist locally, continue anyway
+		fmt.Printf("[Executor] Image pull skipped: %v\n", err)
+	} else {
+		io.Copy(io.Discard, pullOut)
+		pullOut.Close()
+	}
+
+	result := &agent.ExecutionResult{}
+	if inspect, err := cli.ImageInspect(ctx, cfg.Image); err == nil {
+		result.ImageDigest = imageDigest(inspect)
+	}
+
+	// Create container configuration
+	command := cfg.testCommand()
+	containerConfig := &container.Config{
+		Image:      cfg.Image,
+		Cmd:        command,
+		WorkingDir: cfg.WorkDir,
+		Env:        containerEnv(cfg),
+		User:       cfg.User,
+		Tty:        false,
+	}
+
+	hostConfig, err := newHostConfig(cfg, workspaceDir, sourceDir, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the container
+	fmt.Printf("[Executor] Creating container with command: %v\n", command)
+	resp, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+	containerID := resp.ID
+
+	// Ensure cleanup, even when the run was cancelled
+	defer func() {
+		removeCtx, removeCancel := context.WithTimeout(context.WithoutCancel(parent), 30*time.Second)
+		defer removeCancel()
+		if err := cli.ContainerRemove(removeCtx, containerID, container.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil {
+			fmt.Printf("[Executor] Failed to remove container %s: %v\n", containerID[:12], err)
+		}
+	}()
+
+	if mode == copyWorkspace {
+		fmt.Printf("[Executor] Copying workspace to %s in container...\n", cfg.WorkDir)
+		archive := tarWorkspace(workspaceDir, sourceDir)
+		err := cli.CopyToContainer(ctx, containerID, cfg.WorkDir, archive, container.CopyToContainerOptions{})
+		archive.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy workspace to container: %w", err)
+		}
+	}
+
+	// Start the container
+	fmt.Printf("[Executor] Starting container %s...\n", containerID[:12])
+	start := time.Now()
+	if err := cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	// Wait for container to finish
+	statusCh, errCh := cli.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if err == nil {
+			break
+		}
+		if parent.Err() != nil {
+			fmt.Printf("[Executor] Run cancelled, stopping container %s\n", containerID[:12])
+			return nil, fmt.Errorf("container run cancelled: %w", context.Cause(parent))
+		}
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("error waiting for container: %w", err)
+		}
+		result.TimedOut = true
+		result.ExitCode = -1
+		fmt.Printf("[Executor] Container timed out after %s\n", timeout)
+	case status := <-statusCh:
+		result.ExitCode = int(status.StatusCode)
+		fmt.Printf("[Executor] Container exited with code %d\n", status.StatusCode)
+	}
+	result.Duration = time.Since(start)
+
+	// Logs are collected with a fresh deadline so that output produced before
+	// a timeout is still returned.
+	logCtx, logCancel := context.WithTimeout(parent, 30*time.Second)
+	defer logCancel()
+
+	if result.TimedOut {
+		cli.ContainerKill(logCtx, containerID, "KILL")
+	}
+
+	// Get container logs
+	logOptions := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	}
+	logs, err := cli.ContainerLogs(logCtx, containerID, logOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container logs: %w", err)
+	}
+	defer logs.Close()
+
+	// Demultiplex stdout/stderr
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+		return nil, fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if err := checkNetwork(cfg, result); err != nil {
+		return nil, err
+	}
+
+	if cfg.ResultFormat == ResultFormatJUnit {
+		reports, err := copyJUnitReports(logCtx, cli, containerID, cfg.resultPath())
+		if err != nil {
+			// The runner may have crashed before writing reports; the exit
+			// code and logs still describe the run.
+			fmt.Printf("[Executor] No JUnit reports collected: %v\n", err)
+		} else if result.Tests, err = parseJUnitReports(reports); err != nil {
+			return nil, err
+		}
+	} else {
+		parseResults(cfg, result)
+	}
+
+	if profile := cfg.coverProfile(); profile != "" {
+		data, err := copyCoverProfile(logCtx, cli, containerID, profile)
+		if err != nil {
+			// go test writes no profile when a package fails to build
+			fmt.Printf("[Executor] No coverage profile collected: %v\n", err)
+		} else if result.Coverage, err = ParseCoverProfile(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// imageDigest prefers the registry digest of an image and falls back to its
+// local content ID for images that were never pushed or pulled.
+func imageDigest(inspect image.InspectResponse) string {
+	if len(inspect.RepoDigests) > 0 {
+		return inspect.RepoDigests[0]
+	}
+	return inspect.ID
+}
@@ -1,8 +1,18 @@
 package executor
 
 import (
+	"archive/tar"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+
+	"localsprite/internal/agent"
 )
 
 func TestDefaultGoConfig(t *testing.T) {
@@ -69,6 +79,9 @@ func TestNewLocalDockerExecutor_AppliesDefaults(t *testing.T) {
 	if len(exec.Config.Command) == 0 {
 		t.Error("expected default command to be set")
 	}
+	if exec.Config.NetworkMode != "none" {
+		t.Errorf("expected default network mode none, got %s", exec.Config.NetworkMode)
+	}
 }
 
 func TestNewRemoteDockerExecutor_AppliesDefaults(t *testing.T) {
@@ -114,3 +127,617 @@ func TestNewLocalDockerExecutor_PreservesCustomConfig(t *testing.T) {
 		t.Errorf("expected custom command, got %v", exec.Config.Command)
 	}
 }
+
+func TestConfigFromParams(t *testing.T) {
+	cfg, err := ConfigFromParams(map[string]string{
+		"host":              "ssh://test-host",
+		"image":             "golang:1.24-alpine",
+		"command":           "go,test,-v,./...",
+		"workdir":           "/app",
+		"test_file_pattern": "generated_test.go",
+		"timeout":           "120",
+		"coverage":          "true",
+	})
+	if err != nil {
+		t.Fatalf("ConfigFromParams failed: %v", err)
+	}
+
+	if cfg.Host != "ssh://test-host" {
+		t.Errorf("expected host ssh://test-host, got %s", cfg.Host)
+	}
+	if len(cfg.Command) != 4 || cfg.Command[3] != "./..." {
+		t.Errorf("expected split command, got %v", cfg.Command)
+	}
+	if cfg.Timeout != 120 {
+		t.Errorf("expected timeout 120, got %d", cfg.Timeout)
+	}
+	if !cfg.Coverage {
+		t.Error("expected coverage to be enabled")
+	}
+}
+
+func TestConfigFromParams_InvalidTimeout(t *testing.T) {
+	if _, err := ConfigFromParams(map[string]string{"timeout": "soon"}); err == nil {
+		t.Error("expected error for non-numeric timeout")
+	}
+}
+
+func TestParseGoTestVerbose(t *testing.T) {
+	output := `=== RUN   TestAdd
+--- PASS: TestAdd (0.00s)
+=== RUN   TestFail
+    generated_test.go:10: intentional failure
+--- FAIL: TestFail (0.12s)
+=== RUN   TestSkip
+--- SKIP: TestSkip (0.00s)
+FAIL
+FAIL	example.com/app	0.015s
+=== RUN   TestOther
+--- PASS: TestOther (0.00s)
+ok  	example.com/app/other	0.010s
+`
+
+	tests := ParseGoTestVerbose(output)
+	if len(tests) != 4 {
+		t.Fatalf("expected 4 tests, got %d: %+v", len(tests), tests)
+	}
+
+	if tests[1].Name != "TestFail" || tests[1].Status != agent.TestFailed {
+		t.Errorf("expected TestFail to fail, got %+v", tests[1])
+	}
+	if tests[1].Package != "example.com/app" {
+		t.Errorf("expected package example.com/app, got %s", tests[1].Package)
+	}
+	if tests[1].Elapsed != 120*time.Millisecond {
+		t.Errorf("expected 120ms elapsed, got %s", tests[1].Elapsed)
+	}
+	if tests[2].Status != agent.TestSkipped {
+		t.Errorf("expected TestSkip to be skipped, got %+v", tests[2])
+	}
+	if tests[3].Package != "example.com/app/other" {
+		t.Errorf("expected package example.com/app/other, got %s", tests[3].Package)
+	}
+}
+
+func TestParseGoTestJSON(t *testing.T) {
+	output := `{"Action":"start","Package":"example.com/app/a"}
+{"Action":"run","Package":"example.com/app/a","Test":"TestPass"}
+{"Action":"output","Package":"example.com/app/a","Test":"TestPass","Output":"=== RUN   TestPass\n"}
+{"Action":"pass","Package":"example.com/app/a","Test":"TestPass","Elapsed":0.01}
+{"Action":"run","Package":"example.com/app/a","Test":"TestFail"}
+{"Action":"output","Package":"example.com/app/a","Test":"TestFail","Output":"    a_test.go:6: boom\n"}
+{"Action":"fail","Package":"example.com/app/a","Test":"TestFail","Elapsed":0}
+{"Action":"skip","Package":"example.com/app/a","Test":"TestSkip","Elapsed":0}
+{"Action":"output","Package":"example.com/app/a","Output":"FAIL\texample.com/app/a\t0.003s\n"}
+{"Action":"fail","Package":"example.com/app/a","Elapsed":0.004}
+{"ImportPath":"example.com/app/b [example.com/app/b.test]","Action":"build-output","Output":"b/b_test.go:5:33: undefined: undefined\n"}
+{"ImportPath":"example.com/app/b [example.com/app/b.test]","Action":"build-fail"}
+{"Action":"fail","Package":"example.com/app/b","Elapsed":0,"FailedBuild":"example.com/app/b [example.com/app/b.test]"}
+`
+
+	tests, packages := ParseGoTestJSON(output)
+	if len(tests) != 3 {
+		t.Fatalf("expected 3 tests, got %d: %+v", len(tests), tests)
+	}
+	if tests[0].Status != agent.TestPassed || tests[0].Elapsed != 10*time.Millisecond {
+		t.Errorf("expected TestPass to pass in 10ms, got %+v", tests[0])
+	}
+	if tests[1].Status != agent.TestFailed || !strings.Contains(tests[1].Output, "boom") {
+		t.Errorf("expected TestFail to fail with captured output, got %+v", tests[1])
+	}
+	if tests[2].Status != agent.TestSkipped {
+		t.Errorf("expected TestSkip to be skipped, got %+v", tests[2])
+	}
+
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d: %+v", len(packages), packages)
+	}
+	if packages[1].Name != "example.com/app/b" || packages[1].Status != agent.TestFailed {
+		t.Errorf("expected example.com/app/b to fail, got %+v", packages[1])
+	}
+	if !strings.Contains(packages[1].Output, "undefined: undefined") {
+		t.Errorf("expected build output on failed package, got %q", packages[1].Output)
+	}
+}
+
+func TestTestCommand_AddsJSONFlag(t *testing.T) {
+	cfg := DefaultGoConfig()
+	cfg.ResultFormat = ResultFormatGoJSON
+
+	cmd := cfg.testCommand()
+	if strings.Join(cmd, " ") != "go test -json -v ./..." {
+		t.Errorf("expected -json to be added, got %v", cmd)
+	}
+
+	cfg.Command = []string{"go", "test", "-json", "./..."}
+	if len(cfg.testCommand()) != 4 {
+		t.Errorf("expected existing -json to be kept, got %v", cfg.testCommand())
+	}
+
+	cfg.ResultFormat = ResultFormatText
+	cfg.Command = []string{"go", "test", "./..."}
+	if len(cfg.testCommand()) != 3 {
+		t.Errorf("expected text mode to leave command alone, got %v", cfg.testCommand())
+	}
+}
+
+func TestTestCommand_AddsCoverProfile(t *testing.T) {
+	cfg := DefaultGoConfig()
+	cfg.Coverage = true
+	cfg.ResultFormat = ResultFormatGoJSON
+
+	if got := strings.Join(cfg.testCommand(), " "); got != "go test -json -coverprofile=/tmp/localsprite.coverprofile -v ./..." {
+		t.Errorf("expected -json and -coverprofile to be added, got %s", got)
+	}
+	if got := cfg.coverProfile(); got != "/tmp/localsprite.coverprofile" {
+		t.Errorf("expected default profile path, got %s", got)
+	}
+
+	cfg.Command = []string{"go", "test", "-coverprofile", "cover.out", "./..."}
+	if got := strings.Join(cfg.testCommand(), " "); got != "go test -json -coverprofile cover.out ./..." {
+		t.Errorf("expected existing -coverprofile to be kept, got %s", got)
+	}
+	if got := cfg.coverProfile(); got != "/app/cover.out" {
+		t.Errorf("expected profile relative to workdir, got %s", got)
+	}
+
+	cfg.Command = []string{"npx", "playwright", "test"}
+	if got := cfg.coverProfile(); got != "" {
+		t.Errorf("expected no profile for other runners, got %s", got)
+	}
+}
+
+func TestTestCommand_Repeat(t *testing.T) {
+	cfg := DefaultGoConfig()
+	cfg.Repeat = 5
+	cfg.Shuffle = true
+	if got := strings.Join(cfg.testCommand(), " "); got != "go test -count=5 -shuffle=on -v ./..." {
+		t.Errorf("expected -count and -shuffle to be added, got %s", got)
+	}
+
+	cfg.Command = []string{"go", "test", "-count=1", "./..."}
+	if got := strings.Join(cfg.testCommand(), " "); got != "go test -shuffle=on -count=1 ./..." {
+		t.Errorf("expected existing -count to be kept, got %s", got)
+	}
+
+	cfg, err := ConfigFromParams(map[string]string{"repeat": "3", "shuffle": "true"})
+	if err != nil || cfg.Repeat != 3 || !cfg.Shuffle {
+		t.Errorf("expected repeat and shuffle params, got %+v %v", cfg, err)
+	}
+	if _, err := ConfigFromParams(map[string]string{"repeat": "often"}); err == nil {
+		t.Error("expected error for non-numeric repeat")
+	}
+}
+
+func TestConfigFromParams_Sandbox(t *testing.T) {
+	cfg, err := ConfigFromParams(map[string]string{
+		"memory":     "512m",
+		"cpus":       "1.5",
+		"pids_limit": "256",
+		"network":    "bridge",
+		"read_only":  "true",
+		"user":       "65534:65534",
+		"cap_drop":   "ALL, NET_RAW",
+		"seccomp":    "unconfined",
+	})
+	if err != nil {
+		t.Fatalf("ConfigFromParams failed: %v", err)
+	}
+	if cfg.Memory != 512<<20 || cfg.CPUs != 1.5 || cfg.PidsLimit != 256 {
+		t.Errorf("expected resource limits, got %+v", cfg)
+	}
+	if cfg.NetworkMode != "bridge" || !cfg.ReadOnly || cfg.User != "65534:65534" || cfg.SeccompProfile != "unconfined" {
+		t.Errorf("expected isolation settings, got %+v", cfg)
+	}
+	if strings.Join(cfg.CapDrop, ",") != "ALL,NET_RAW" {
+		t.Errorf("expected split capabilities, got %v", cfg.CapDrop)
+	}
+
+	for _, params := range []map[string]string{
+		{"memory": "lots"},
+		{"cpus": "-1"},
+		{"pids_limit": "many"},
+		{"read_only": "yes please"},
+	} {
+		if _, err := ConfigFromParams(params); err == nil {
+			t.Errorf("expected error for %v", params)
+		}
+	}
+}
+
+func TestNewHostConfig(t *testing.T) {
+	cfg := NewLocalDockerExecutor(ExecutorConfig{
+		Memory:    256 << 20,
+		CPUs:      0.5,
+		PidsLimit: 100,
+	}).Config
+
+	hc, err := newHostConfig(cfg, "/tmp/ws", "", bindWorkspace)
+	if err != nil {
+		t.Fatalf("newHostConfig failed: %v", err)
+	}
+	if hc.NetworkMode != "none" || hc.ReadonlyRootfs {
+		t.Errorf("expected an isolated writable container, got %+v", hc)
+	}
+	if hc.Memory != 256<<20 || hc.MemorySwap != 256<<20 || hc.NanoCPUs != 5e8 || hc.PidsLimit == nil || *hc.PidsLimit != 100 {
+		t.Errorf("expected resource limits, got %+v", hc.Resources)
+	}
+	if len(hc.CapDrop) != 0 {
+		t.Errorf("expected no capabilities dropped without source, got %v", hc.CapDrop)
+	}
+	if len(hc.Mounts) != 1 || hc.Mounts[0].Source != "/tmp/ws" || hc.Mounts[0].Target != "/app" {
+		t.Errorf("expected the workspace bind mount, got %+v", hc.Mounts)
+	}
+
+	cfg.CapDrop = []string{"NET_RAW"}
+	if hc, _ = newHostConfig(cfg, "/tmp/ws", "/src", copyWorkspace); strings.Join(hc.CapDrop, ",") != "NET_RAW,DAC_OVERRIDE" || len(hc.Mounts) != 0 {
+		t.Errorf("expected DAC_OVERRIDE dropped for the source and no mounts, got %v %+v", hc.CapDrop, hc.Mounts)
+	}
+	cfg.CapDrop = []string{"all"}
+	if hc, _ = newHostConfig(cfg, "/tmp/ws", "/src", copyWorkspace); strings.Join(hc.CapDrop, ",") != "all" {
+		t.Errorf("expected ALL to cover DAC_OVERRIDE, got %v", hc.CapDrop)
+	}
+}
+
+func TestNewHostConfig_ReadOnly(t *testing.T) {
+	cfg := NewRemoteDockerExecutor(ExecutorConfig{Host: "ssh://test-host", ReadOnly: true}).Config
+
+	hc, err := newHostConfig(cfg, "/tmp/ws", "/src", copyWorkspace)
+	if err != nil {
+		t.Fatalf("newHostConfig failed: %v", err)
+	}
+	if !hc.ReadonlyRootfs {
+		t.Error("expected a read-only root filesystem")
+	}
+	var targets []string
+	for _, m := range hc.Mounts {
+		if m.Type != mount.TypeVolume || m.Source != "" {
+			t.Errorf("expected anonymous volumes, got %+v", m)
+		}
+		targets = append(targets, m.Target)
+	}
+	if strings.Join(targets, ",") != "/app,/tmp" {
+		t.Errorf("expected writable workdir and /tmp, got %v", targets)
+	}
+
+	env := containerEnv(cfg)
+	if len(env) != 1 || env[0] != "GOCACHE=/tmp/go-build" {
+		t.Errorf("expected the Go build cache in /tmp, got %v", env)
+	}
+	cfg.Env = []string{"GOCACHE=/cache"}
+	if env = containerEnv(cfg); len(env) != 1 {
+		t.Errorf("expected a configured GOCACHE to be kept, got %v", env)
+	}
+}
+
+func TestCheckNetwork(t *testing.T) {
+	cfg := NewLocalDockerExecutor(ExecutorConfig{}).Config
+	text := "x.go:2:8: github.com/google/uuid@v1.6.0: Get \"https://proxy.golang.org/github.com/google/uuid/@v/v1.6.0.zip\": dial tcp: lookup proxy.golang.org: no such host\n"
+	event := `{"ImportPath":"x","Action":"build-output","Output":"x.go:2:8: github.com/google/uuid@v1.6.0: Get \"https://proxy.golang.org/github.com/google/uuid/@v/v1.6.0.zip\": dial tcp: lookup proxy.golang.org: no such host\n"}`
+
+	if err := checkNetwork(cfg, &agent.ExecutionResult{ExitCode: 1, Stderr: text}); err == nil || !strings.Contains(err.Error(), "network") {
+		t.Errorf("expected a network error for text output, got %v", err)
+	}
+	if err := checkNetwork(cfg, &agent.ExecutionResult{ExitCode: 1, Stdout: event}); err == nil {
+		t.Error("expected a network error for JSON output")
+	}
+	if err := checkNetwork(cfg, &agent.ExecutionResult{ExitCode: 1, Stdout: "--- FAIL: TestX (0.00s)\n"}); err != nil {
+		t.Errorf("expected test failures to be left to the repair loop, got %v", err)
+	}
+	cfg.NetworkMode = "bridge"
+	if err := checkNetwork(cfg, &agent.ExecutionResult{ExitCode: 1, Stderr: text}); err != nil {
+		t.Errorf("expected no network error with a network, got %v", err)
+	}
+}
+
+func TestNewHostConfig_Seccomp(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "seccomp.json")
+	if err := os.WriteFile(profile, []byte(`{"defaultAction":"SCMP_ACT_ERRNO"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultGoConfig()
+	cfg.SeccompProfile = profile
+
+	hc, err := newHostConfig(cfg, "/tmp/ws", "", bindWorkspace)
+	if err != nil {
+		t.Fatalf("newHostConfig failed: %v", err)
+	}
+	if strings.Join(hc.SecurityOpt, " ") != `no-new-privileges seccomp={"defaultAction":"SCMP_ACT_ERRNO"}` {
+		t.Errorf("expected the profile contents, got %v", hc.SecurityOpt)
+	}
+
+	cfg.SeccompProfile = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := newHostConfig(cfg, "/tmp/ws", "", bindWorkspace); err == nil {
+		t.Error("expected error for a missing profile")
+	}
+}
+
+func TestWithCommand(t *testing.T) {
+	cfg := DefaultGoConfig()
+	cfg.Coverage = true
+	cfg.ResultFormat = ResultFormatGoJSON
+
+	got := cfg.withCommand([]string{"sh", "run.sh"}, 10*time.Minute)
+	if strings.Join(got.testCommand(), " ") != "sh run.sh" || got.ResultFormat != ResultFormatText || got.coverProfile() != "" || got.Timeout != 600 {
+		t.Errorf("expected the command to run as is, got %+v", got)
+	}
+	if len(cfg.Command) != 4 || !cfg.Coverage {
+		t.Errorf("expected the original config to be left alone, got %+v", cfg)
+	}
+}
+
+func TestParseCoverProfile(t *testing.T) {
+	profile := `mode: set
+example.com/shop/cart/cart.go:5.30,7.2 2 1
+example.com/shop/cart/cart.go:9.25,11.2 1 0
+example.com/shop/cart/cart.go:9.25,11.2 1 1
+`
+	blocks, err := ParseCoverProfile([]byte(profile))
+	if err != nil {
+		t.Fatalf("ParseCoverProfile failed: %v", err)
+	}
+	want := []agent.CoverBlock{
+		{File: "example.com/shop/cart/cart.go", StartLine: 5, StartCol: 30, EndLine: 7, EndCol: 2, Statements: 2, Count: 1},
+		{File: "example.com/shop/cart/cart.go", StartLine: 9, StartCol: 25, EndLine: 11, EndCol: 2, Statements: 1, Count: 1},
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("expected %d blocks, got %+v", len(want), blocks)
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("block %d: expected %+v, got %+v", i, want[i], blocks[i])
+		}
+	}
+
+	if _, err := ParseCoverProfile([]byte("mode: set\nnot a block\n")); err == nil {
+		t.Error("expected error for malformed profile")
+	}
+}
+
+func TestParseJUnit_Playwright(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites id="" name="" tests="3" failures="1" skipped="1" errors="0" time="2.5">
+<testsuite name="generated.spec.ts" timestamp="2024-01-01T00:00:00" hostname="chromium" tests="3" failures="1" skipped="1" time="2.5" errors="0">
+<testcase name="login › shows form" classname="generated.spec.ts" time="0.75">
+</testcase>
+<testcase name="login › rejects bad password" classname="generated.spec.ts" time="1.2">
+<failure message="generated.spec.ts:12:5 rejects bad password" type="FAILURE">
+<![CDATA[Error: expect(received).toBeVisible()]]>
+</failure>
+<system-out>
+<![CDATA[navigating to /login]]>
+</system-out>
+</testcase>
+<testcase name="login › remembers user" classname="generated.spec.ts" time="0">
+<skipped>
+</skipped>
+</testcase>
+</testsuite>
+</testsuites>`
+
+	tests, err := ParseJUnit([]byte(report))
+	if err != nil {
+		t.Fatalf("ParseJUnit failed: %v", err)
+	}
+	if len(tests) != 3 {
+		t.Fatalf("expected 3 tests, got %d", len(tests))
+	}
+	if tests[0].Status != agent.TestPassed || tests[0].Elapsed != 750*time.Millisecond {
+		t.Errorf("expected first test to pass in 750ms, got %+v", tests[0])
+	}
+	if tests[1].Status != agent.TestFailed || tests[1].Package != "generated.spec.ts" {
+		t.Errorf("expected second test to fail, got %+v", tests[1])
+	}
+	if !strings.Contains(tests[1].Output, "toBeVisible") || !strings.Contains(tests[1].Output, "navigating to /login") {
+		t.Errorf("expected failure body and system-out in output, got %q", tests[1].Output)
+	}
+	if tests[2].Status != agent.TestSkipped {
+		t.Errorf("expected third test to be skipped, got %+v", tests[2])
+	}
+}
+
+func TestParseJUnit_CypressSingleSuite(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="Root Suite" tests="1" failures="0" time="0.5">
+  <testsuite name="home page" tests="1" failures="1" time="0.5">
+    <testcase name="home page loads" time="0.5" classname="loads">
+      <error message="Timed out retrying"/>
+    </testcase>
+  </testsuite>
+</testsuite>`
+
+	tests, err := ParseJUnit([]byte(report))
+	if err != nil {
+		t.Fatalf("ParseJUnit failed: %v", err)
+	}
+	if len(tests) != 1 || tests[0].Package != "home page" || tests[0].Status != agent.TestFailed {
+		t.Errorf("expected nested failing test, got %+v", tests)
+	}
+}
+
+func TestParseJUnit_RejectsOtherXML(t *testing.T) {
+	if _, err := ParseJUnit([]byte(`<coverage/>`)); err == nil {
+		t.Error("expected error for non-junit root element")
+	}
+}
+
+func TestResultPath(t *testing.T) {
+	cfg := DefaultCypressConfig()
+	if got := cfg.resultPath(); got != "/e2e/results" {
+		t.Errorf("expected /e2e/results, got %s", got)
+	}
+	cfg.ResultPath = "/tmp/reports"
+	if got := cfg.resultPath(); got != "/tmp/reports" {
+		t.Errorf("expected absolute path to be kept, got %s", got)
+	}
+}
+
+func TestTarWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "testdata"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "generated_test.go"), []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "testdata", "input.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := tarWorkspace(dir, "")
+	defer archive.Close()
+
+	contents := map[string]string{}
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		contents[hdr.Name] = string(data)
+	}
+
+	if contents["generated_test.go"] != "package main" {
+		t.Errorf("expected generated_test.go at archive root, got %v", contents)
+	}
+	if _, ok := contents["testdata/"]; !ok {
+		t.Errorf("expected testdata/ directory entry, got %v", contents)
+	}
+	if contents["testdata/input.json"] != "{}" {
+		t.Errorf("expected nested file, got %v", contents)
+	}
+}
+
+func TestTarWorkspace_Source(t *testing.T) {
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":            "module example.com/shop\n",
+		".gitignore":        "bin/\n",
+		"bin/shop":          "binary",
+		".git/HEAD":         "ref: refs/heads/main\n",
+		"cart/cart.go":      "package cart",
+		"cart/cart_test.go": "package cart // original",
+	} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws := agent.Workspace{SourceDir: src, TestDir: "cart"}
+	files := []agent.Artifact{
+		{Content: "package cart // generated"},
+		{Path: "cart/testdata/items.json", Content: "[]"},
+	}
+	dir, resolved, err := newWorkspace(ws, "cart_test.go", files)
+	if err != nil {
+		t.Fatalf("newWorkspace failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if resolved[0].Path != "cart/cart_test.go" || resolved[1].Path != "cart/testdata/items.json" {
+		t.Errorf("expected resolved paths, got %+v", resolved)
+	}
+
+	archive := tarWorkspace(dir, src)
+	defer archive.Close()
+
+	contents := map[string]string{}
+	modes := map[string]int64{}
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		if _, dup := contents[hdr.Name]; dup {
+			t.Errorf("duplicate archive entry %s", hdr.Name)
+		}
+		data, _ := io.ReadAll(tr)
+		contents[hdr.Name] = string(data)
+		modes[hdr.Name] = hdr.Mode
+	}
+
+	if contents["cart/cart_test.go"] != "package cart // generated" {
+		t.Errorf("expected generated file in the package dir to replace the original, got %q", contents["cart/cart_test.go"])
+	}
+	if contents["cart/testdata/items.json"] != "[]" {
+		t.Errorf("expected named fixture in the archive, got %v", contents)
+	}
+	if modes["cart/cart_test.go"]&0200 == 0 {
+		t.Errorf("expected generated file to be writable, got %o", modes["cart/cart_test.go"])
+	}
+	for _, name := range []string{"go.mod", "cart/cart.go"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("expected source file %s in archive", name)
+		}
+		if modes[name]&0222 != 0 {
+			t.Errorf("expected %s to be read-only, got %o", name, modes[name])
+		}
+	}
+	for _, name := range []string{".git/HEAD", "bin/shop"} {
+		if _, ok := contents[name]; ok {
+			t.Errorf("expected %s to be skipped", name)
+		}
+	}
+}
+
+func TestNewWorkspace_RejectsEscapingPaths(t *testing.T) {
+	cases := []struct {
+		ws    agent.Workspace
+		files []agent.Artifact
+	}{
+		{agent.Workspace{SourceDir: t.TempDir(), TestDir: "../outside"}, []agent.Artifact{{Content: "package x"}}},
+		{agent.Workspace{}, []agent.Artifact{{Path: "../../outside_test.go", Content: "package x"}}},
+		{agent.Workspace{}, []agent.Artifact{{Path: "/tmp/outside_test.go", Content: "package x"}}},
+	}
+	for _, c := range cases {
+		if dir, _, err := newWorkspace(c.ws, "generated_test.go", c.files); err == nil {
+			os.RemoveAll(dir)
+			t.Errorf("expected an error for %+v %+v", c.ws, c.files)
+		}
+	}
+}
+
+func TestNewWorkspace_NoFiles(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, resolved, err := newWorkspace(agent.Workspace{SourceDir: src, TestDir: "."}, "generated_test.go", nil)
+	if err != nil {
+		t.Fatalf("expected a baseline workspace, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if len(resolved) != 0 {
+		t.Errorf("expected no files, got %+v", resolved)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected an empty workspace, got %v", entries)
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	u, _ := url.Parse("ssh://builder@imperial-construct:2222")
+	got := strings.Join(sshArgs(u), " ")
+	want := "-l builder -p 2222 -- imperial-construct docker system dial-stdio"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRemoteClientOpts_RejectsMissingHostname(t *testing.T) {
+	if _, err := remoteClientOpts("ssh://"); err == nil {
+		t.Error("expected error for ssh host without hostname")
+	}
+}
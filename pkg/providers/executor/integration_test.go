@@ -1,11 +1,18 @@
+//go:build integration
 // +build integration
 
 package executor
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"localsprite/internal/agent"
 )
 
 // Integration tests require Docker to be running
@@ -33,14 +40,17 @@ func TestLocalDockerExecutor_Integration(t *testing.T) {
 func main() {}
 `
 
-	output, err := exec.Execute(code)
+	result, err := exec.Execute(context.Background(), agent.Workspace{}, []agent.Artifact{{Content: code}})
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
 	}
 
 	// Should contain Go version info
-	if !strings.Contains(output, "go version") {
-		t.Errorf("expected output to contain 'go version', got: %s", output)
+	if !strings.Contains(result.Stdout, "go version") {
+		t.Errorf("expected output to contain 'go version', got: %s", result.Output())
+	}
+	if result.ImageDigest == "" {
+		t.Error("expected image digest to be recorded")
 	}
 }
 
@@ -70,14 +80,17 @@ func Add(a, b int) int {
 }
 `
 
-	output, err := exec.Execute(code)
+	result, err := exec.Execute(context.Background(), agent.Workspace{}, []agent.Artifact{{Content: code}})
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
 	}
 
 	// Should indicate test passed
-	if !strings.Contains(output, "PASS") && !strings.Contains(output, "ok") {
-		t.Errorf("expected test to pass, got: %s", output)
+	if !result.Passed() {
+		t.Errorf("expected test to pass, got exit code %d: %s", result.ExitCode, result.Output())
+	}
+	if len(result.Tests) != 1 || result.Tests[0].Name != "TestAdd" {
+		t.Errorf("expected TestAdd outcome, got %+v", result.Tests)
 	}
 }
 
@@ -100,15 +113,95 @@ func TestFail(t *testing.T) {
 }
 `
 
-	output, err := exec.Execute(code)
+	result, err := exec.Execute(context.Background(), agent.Workspace{}, []agent.Artifact{{Content: code}})
 	// Execute should not error even if test fails - we capture the output
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
 	}
 
 	// Should indicate test failed
-	if !strings.Contains(output, "FAIL") {
-		t.Errorf("expected test to fail, got: %s", output)
+	if result.Passed() || result.ExitCode == 0 {
+		t.Errorf("expected test to fail, got exit code %d: %s", result.ExitCode, result.Output())
+	}
+	if failed := result.Failed(); len(failed) != 1 || failed[0].Name != "TestFail" {
+		t.Errorf("expected TestFail to be reported, got %+v", result.Tests)
+	}
+}
+
+func TestLocalDockerExecutor_Source_Integration(t *testing.T) {
+	if os.Getenv("DOCKER_HOST") == "" && !dockerAvailable() {
+		t.Skip("Docker not available, skipping integration test")
+	}
+
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":       "module example.com/calc\n\ngo 1.22\n",
+		"calc/calc.go": "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+	} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := DefaultGoConfig()
+	cfg.Image = "golang:1.22-alpine"
+	exec := NewLocalDockerExecutor(cfg)
+
+	// The generated test uses the package under test and cannot write to
+	// its sources
+	code := `package calc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Error("expected 3")
+	}
+	if err := os.WriteFile("calc.go", nil, 0644); err == nil {
+		t.Error("expected calc.go to be read-only")
+	}
+}
+`
+
+	ws := agent.Workspace{SourceDir: src, TestDir: "calc"}
+	result, err := exec.Execute(context.Background(), ws, []agent.Artifact{{Content: code}})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !result.Passed() {
+		t.Errorf("expected test to pass against the source, got exit code %d: %s", result.ExitCode, result.Output())
+	}
+}
+
+func TestLocalDockerExecutor_Cancel_Integration(t *testing.T) {
+	if os.Getenv("DOCKER_HOST") == "" && !dockerAvailable() {
+		t.Skip("Docker not available, skipping integration test")
+	}
+
+	cfg := ExecutorConfig{
+		Image:   "golang:1.22-alpine",
+		Command: []string{"sleep", "60"},
+		Timeout: 120,
+	}
+	exec := NewLocalDockerExecutor(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := exec.Execute(ctx, agent.Workspace{}, []agent.Artifact{{Content: "package main\n"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 45*time.Second {
+		t.Errorf("expected cancellation to stop the container promptly, took %s", elapsed)
 	}
 }
 
@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+
+	"localsprite/internal/agent"
+)
+
+// maxReportSize caps how much of a single report file is read from the
+// container.
+const maxReportSize = 16 << 20
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+	SystemErr string        `xml:"system-err"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// ParseJUnit parses a JUnit XML report with either a <testsuites> or a
+// <testsuite> root into per-test outcomes. The suite name is reported as the
+// package of each test.
+func ParseJUnit(data []byte) ([]agent.TestOutcome, error) {
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse junit report: %w", err)
+	}
+
+	switch root.XMLName.Local {
+	case "testsuites", "testsuite":
+	default:
+		return nil, fmt.Errorf("failed to parse junit report: unexpected root element <%s>", root.XMLName.Local)
+	}
+
+	var tests []agent.TestOutcome
+	collectJUnit(root.junitSuite, &tests)
+	return tests, nil
+}
+
+func collectJUnit(suite junitSuite, tests *[]agent.TestOutcome) {
+	for _, c := range suite.Cases {
+		secs, _ := strconv.ParseFloat(strings.ReplaceAll(c.Time, ",", ""), 64)
+		outcome := agent.TestOutcome{
+			Package: suite.Name,
+			Name:    c.Name,
+			Status:  agent.TestPassed,
+			Elapsed: time.Duration(secs * float64(time.Second)),
+		}
+
+		var out strings.Builder
+		switch {
+		case c.Failure != nil:
+			outcome.Status = agent.TestFailed
+			writeJUnitMessage(&out, c.Failure)
+		case c.Error != nil:
+			outcome.Status = agent.TestFailed
+			writeJUnitMessage(&out, c.Error)
+		case c.Skipped != nil:
+			outcome.Status = agent.TestSkipped
+			writeJUnitMessage(&out, c.Skipped)
+		}
+		if s := strings.TrimSpace(c.SystemOut); s != "" {
+			out.WriteString(s + "\n")
+		}
+		if s := strings.TrimSpace(c.SystemErr); s != "" {
+			out.WriteString(s + "\n")
+		}
+		outcome.Output = out.String()
+
+		*tests = append(*tests, outcome)
+	}
+
+	for _, nested := range suite.Suites {
+		collectJUnit(nested, tests)
+	}
+}
+
+func writeJUnitMessage(out *strings.Builder, m *junitMessage) {
+	if m.Message != "" {
+		out.WriteString(strings.TrimSpace(m.Message) + "\n")
+	}
+	if body := strings.TrimSpace(m.Body); body != "" && body != strings.TrimSpace(m.Message) {
+		out.WriteString(body + "\n")
+	}
+}
+
+// copyJUnitReports copies the XML files at srcPath (a file or a directory)
+// out of the container, keyed by their path within the archive.
+func copyJUnitReports(ctx context.Context, cli *client.Client, containerID, srcPath string) (map[string][]byte, error) {
+	rc, _, err := cli.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container: %w", srcPath, err)
+	}
+	defer rc.Close()
+
+	return readXMLFromTar(rc)
+}
+
+func readXMLFromTar(r io.Reader) (map[string][]byte, error) {
+	reports := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || path.Ext(hdr.Name) != ".xml" {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(tr, maxReportSize)); err != nil {
+			return nil, fmt.Errorf("failed to read report %s: %w", hdr.Name, err)
+		}
+		reports[hdr.Name] = buf.Bytes()
+	}
+	return reports, nil
+}
+
+// parseJUnitReports parses every report in name order and concatenates their
+// outcomes.
+func parseJUnitReports(reports map[string][]byte) ([]agent.TestOutcome, error) {
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tests []agent.TestOutcome
+	for _, name := range names {
+		parsed, err := ParseJUnit(reports[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		tests = append(tests, parsed...)
+	}
+	return tests, nil
+}
@@ -1,19 +1,14 @@
 package executor
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
+
+	"localsprite/internal/agent"
 )
 
 type LocalDockerExecutor struct {
@@ -34,122 +29,54 @@ func NewLocalDockerExecutor(cfg ExecutorConfig) *LocalDockerExecutor {
 	if len(cfg.Command) == 0 {
 		cfg.Command = []string{"go", "test", "-v", "./..."}
 	}
+	if cfg.NetworkMode == "" {
+		cfg.NetworkMode = DefaultNetworkMode
+	}
 
 	return &LocalDockerExecutor{Config: cfg}
 }
 
-func (l *LocalDockerExecutor) Execute(code string) (string, error) {
-	timeout := time.Duration(l.Config.Timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+func (l *LocalDockerExecutor) Execute(ctx context.Context, ws agent.Workspace, files []agent.Artifact) (*agent.ExecutionResult, error) {
+	return l.execute(ctx, l.Config, ws, files)
+}
 
+// ExecuteCommand implements agent.CommandExecutor. Its output is parsed as
+// plain text, no coverage is collected and the run may take up to timeout
+// when that is longer than the configured timeout.
+func (l *LocalDockerExecutor) ExecuteCommand(ctx context.Context, ws agent.Workspace, files []agent.Artifact, command []string, timeout time.Duration) (*agent.ExecutionResult, error) {
+	return l.execute(ctx, l.Config.withCommand(command, timeout), ws, files)
+}
+
+func (l *LocalDockerExecutor) execute(ctx context.Context, cfg ExecutorConfig, ws agent.Workspace, files []agent.Artifact) (*agent.ExecutionResult, error) {
 	// Create Docker client using default socket
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to create docker client: %w", err)
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
 	defer cli.Close()
 
 	fmt.Printf("[Executor] Connected to local Docker\n")
 
-	// Write code to a temporary file that will be mounted
-	tempDir, err := os.MkdirTemp("", "localsprite-test-*")
+	// Write the files to a temporary directory that is mounted, or copied
+	// together with the project source since the originals must stay
+	// read-only
+	tempDir, resolved, err := newWorkspace(ws, cfg.TestFilePattern, files)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return nil, err
 	}
 	defer os.RemoveAll(tempDir)
 
-	testFile := filepath.Join(tempDir, l.Config.TestFilePattern)
-	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
-		return "", fmt.Errorf("failed to write test file: %w", err)
-	}
-
-	// Ensure the image is available
-	fmt.Printf("[Executor] Pulling image %s (if needed)...\n", l.Config.Image)
-	pullOut, err := cli.ImagePull(ctx, l.Config.Image, image.PullOptions{})
-	if err != nil {
-		// Image might already exist locally, continue anyway
-		fmt.Printf("[Executor] Image pull skipped: %v\n", err)
-	} else {
-		io.Copy(io.Discard, pullOut)
-		pullOut.Close()
-	}
-
-	// Create container configuration
-	containerConfig := &container.Config{
-		Image:      l.Config.Image,
-		Cmd:        l.Config.Command,
-		WorkingDir: l.Config.WorkDir,
-		Tty:        false,
+	mode := bindWorkspace
+	if ws.SourceDir != "" {
+		mode = copyWorkspace
 	}
-
-	hostConfig := &container.HostConfig{
-		Mounts: []mount.Mount{
-			{
-				Type:   mount.TypeBind,
-				Source: tempDir,
-				Target: l.Config.WorkDir,
-			},
-		},
-		AutoRemove: false,
-	}
-
-	// Create the container
-	fmt.Printf("[Executor] Creating container with command: %v\n", l.Config.Command)
-	resp, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	result, err := runTests(ctx, cli, cfg, tempDir, ws.SourceDir, mode)
 	if err != nil {
-		return "", fmt.Errorf("failed to create container: %w", err)
+		return nil, err
 	}
-	containerID := resp.ID
-
-	// Ensure cleanup
-	defer func() {
-		removeCtx, removeCancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer removeCancel()
-		cli.ContainerRemove(removeCtx, containerID, container.RemoveOptions{Force: true})
-	}()
-
-	// Start the container
-	fmt.Printf("[Executor] Starting container %s...\n", containerID[:12])
-	if err := cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
-		return "", fmt.Errorf("failed to start container: %w", err)
-	}
-
-	// Wait for container to finish
-	statusCh, errCh := cli.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
-	select {
-	case err := <-errCh:
-		if err != nil {
-			return "", fmt.Errorf("error waiting for container: %w", err)
-		}
-	case status := <-statusCh:
-		fmt.Printf("[Executor] Container exited with code %d\n", status.StatusCode)
-	}
-
-	// Get container logs
-	logOptions := container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-	}
-	logs, err := cli.ContainerLogs(ctx, containerID, logOptions)
-	if err != nil {
-		return "", fmt.Errorf("failed to get container logs: %w", err)
-	}
-	defer logs.Close()
-
-	// Demultiplex stdout/stderr
-	var stdout, stderr bytes.Buffer
-	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
-		return "", fmt.Errorf("failed to read logs: %w", err)
-	}
-
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		output += "\n--- STDERR ---\n" + stderr.String()
-	}
-
-	return output, nil
+	result.Files = resolved
+	return result, nil
 }
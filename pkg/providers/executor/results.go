@@ -0,0 +1,169 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"localsprite/internal/agent"
+)
+
+var (
+	// goTestResultLine matches "--- PASS: TestName (0.00s)" lines, which may
+	// be indented for subtests.
+	goTestResultLine = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([\d.]+)s\)`)
+
+	// goPackageResultLine matches the per-package summary printed after its
+	// tests: "ok  \tpkg\t0.01s" or "FAIL\tpkg\t0.01s".
+	goPackageResultLine = regexp.MustCompile(`^(?:ok|FAIL)\s+(\S+)`)
+)
+
+// parseResults fills in the per-test outcomes of result from its captured
+// output according to the configured result format. JUnit reports are read
+// from the container separately (see copyJUnitReports).
+func parseResults(cfg ExecutorConfig, result *agent.ExecutionResult) {
+	switch cfg.ResultFormat {
+	case ResultFormatGoJSON:
+		result.Tests, result.Packages = ParseGoTestJSON(result.Stdout)
+	default:
+		result.Tests = ParseGoTestVerbose(result.Stdout)
+	}
+}
+
+// ParseGoTestVerbose extracts per-test outcomes from the text output of
+// `go test -v`. Output that is not from go test yields no outcomes.
+func ParseGoTestVerbose(output string) []agent.TestOutcome {
+	var (
+		tests   []agent.TestOutcome
+		pending int // index of the first outcome without a package
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := goTestResultLine.FindStringSubmatch(line); m != nil {
+			secs, _ := strconv.ParseFloat(m[3], 64)
+			tests = append(tests, agent.TestOutcome{
+				Name:    m[2],
+				Status:  goTestStatus(m[1]),
+				Elapsed: time.Duration(secs * float64(time.Second)),
+			})
+			continue
+		}
+
+		if m := goPackageResultLine.FindStringSubmatch(line); m != nil {
+			for i := pending; i < len(tests); i++ {
+				tests[i].Package = m[1]
+			}
+			pending = len(tests)
+		}
+	}
+
+	return tests
+}
+
+func goTestStatus(s string) agent.TestStatus {
+	switch s {
+	case "PASS", "pass":
+		return agent.TestPassed
+	case "SKIP", "skip":
+		return agent.TestSkipped
+	default:
+		return agent.TestFailed
+	}
+}
+
+// goTestEvent is a single line of `go test -json` output (see `go doc test2json`).
+type goTestEvent struct {
+	Action      string
+	Package     string
+	Test        string
+	Elapsed     float64
+	Output      string
+	ImportPath  string
+	FailedBuild string
+}
+
+// ParseGoTestJSON parses the event stream of `go test -json` into per-test
+// and per-package outcomes, each with its elapsed time and captured output.
+// Lines that are not JSON events (e.g. compiler output on older toolchains)
+// are ignored.
+func ParseGoTestJSON(output string) ([]agent.TestOutcome, []agent.PackageOutcome) {
+	type key struct{ pkg, test string }
+
+	var (
+		tests      []agent.TestOutcome
+		packages   []agent.PackageOutcome
+		testOutput = map[key]*strings.Builder{}
+		buildOut   = map[string]*strings.Builder{}
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var ev goTestEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			continue
+		}
+
+		k := key{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			appendOutput(testOutput, k, ev.Output)
+		case "build-output":
+			appendOutput(buildOut, ev.ImportPath, ev.Output)
+		case "pass", "fail", "skip":
+			// Tests repeated with -count report each run separately
+			var captured string
+			if b, ok := testOutput[k]; ok {
+				captured = b.String()
+				delete(testOutput, k)
+			}
+			elapsed := time.Duration(ev.Elapsed * float64(time.Second))
+
+			if ev.Test != "" {
+				tests = append(tests, agent.TestOutcome{
+					Package: ev.Package,
+					Name:    ev.Test,
+					Status:  goTestStatus(ev.Action),
+					Elapsed: elapsed,
+					Output:  captured,
+				})
+				continue
+			}
+
+			if ev.FailedBuild != "" {
+				if b, ok := buildOut[ev.FailedBuild]; ok {
+					captured = b.String() + captured
+				}
+			}
+			packages = append(packages, agent.PackageOutcome{
+				Name:    ev.Package,
+				Status:  goTestStatus(ev.Action),
+				Elapsed: elapsed,
+				Output:  captured,
+			})
+		}
+	}
+
+	return tests, packages
+}
+
+func appendOutput[K comparable](m map[K]*strings.Builder, k K, s string) {
+	b, ok := m[k]
+	if !ok {
+		b = &strings.Builder{}
+		m[k] = b
+	}
+	b.WriteString(s)
+}
@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+
+	"localsprite/internal/agent"
+)
+
+// scratchDir stays writable when the root filesystem is read-only. It is a
+// volume rather than a tmpfs so that the coverage profile written there can
+// still be copied out after the container has exited.
+const scratchDir = "/tmp"
+
+// moduleDownloadFailure matches go failing to download a module, in plain
+// or JSON-escaped output: "example.com/m@v1.0.0: Get "https://...": dial tcp".
+var moduleDownloadFailure = regexp.MustCompile(`@v\S+: Get \S+ dial tcp`)
+
+// newHostConfig returns the resource limits and isolation of the container
+// that runs the tests, along with the mounts that make the workspace
+// available in the given mode.
+func newHostConfig(cfg ExecutorConfig, workspaceDir, sourceDir string, mode workspaceMode) (*container.HostConfig, error) {
+	hc := &container.HostConfig{
+		AutoRemove:     false,
+		NetworkMode:    container.NetworkMode(cfg.NetworkMode),
+		ReadonlyRootfs: cfg.ReadOnly,
+		CapDrop:        append([]string(nil), cfg.CapDrop...),
+		// Generated code has no business gaining privileges through setuid
+		// binaries of the image
+		SecurityOpt: []string{"no-new-privileges"},
+		Resources: container.Resources{
+			Memory:   cfg.Memory,
+			NanoCPUs: int64(cfg.CPUs * 1e9),
+		},
+	}
+	if cfg.Memory > 0 {
+		// Without it the container may swap as much again
+		hc.MemorySwap = cfg.Memory
+	}
+	if cfg.PidsLimit > 0 {
+		pids := cfg.PidsLimit
+		hc.PidsLimit = &pids
+	}
+	if sourceDir != "" && !dropsCapability(hc.CapDrop, "DAC_OVERRIDE") {
+		// Without it root ignores the permission bits that keep the
+		// project sources read-only
+		hc.CapDrop = append(hc.CapDrop, "DAC_OVERRIDE")
+	}
+	if cfg.SeccompProfile != "" {
+		opt, err := seccompOpt(cfg.SeccompProfile)
+		if err != nil {
+			return nil, err
+		}
+		hc.SecurityOpt = append(hc.SecurityOpt, opt)
+	}
+
+	switch {
+	case mode == bindWorkspace:
+		hc.Mounts = append(hc.Mounts, mount.Mount{
+			Type:   mount.TypeBind,
+			Source: workspaceDir,
+			Target: cfg.WorkDir,
+		})
+	case cfg.ReadOnly:
+		// The copy API can only write to a read-only container through
+		// its volumes
+		hc.Mounts = append(hc.Mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Target: cfg.WorkDir,
+		})
+	}
+	if cfg.ReadOnly && path.Clean(cfg.WorkDir) != scratchDir {
+		hc.Mounts = append(hc.Mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Target: scratchDir,
+		})
+	}
+	return hc, nil
+}
+
+// containerEnv returns the environment of the container. With a read-only
+// root filesystem or a user other than the image's, the home directory may
+// not be writable, so the Go build cache moves to scratchDir unless Env
+// places it.
+func containerEnv(cfg ExecutorConfig) []string {
+	if !cfg.ReadOnly && cfg.User == "" {
+		return cfg.Env
+	}
+	for _, kv := range cfg.Env {
+		if strings.HasPrefix(kv, "GOCACHE=") {
+			return cfg.Env
+		}
+	}
+	env := append([]string(nil), cfg.Env...)
+	return append(env, "GOCACHE="+path.Join(scratchDir, "go-build"))
+}
+
+// dropsCapability reports whether capDrop drops the capability name, on its
+// own or through ALL.
+func dropsCapability(capDrop []string, name string) bool {
+	for _, c := range capDrop {
+		c = strings.TrimPrefix(strings.ToUpper(c), "CAP_")
+		if c == "ALL" || c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// seccompOpt returns the security option applying the seccomp profile. The
+// daemon expects the contents of the profile, which may be a path on
+// another machine than the daemon's.
+func seccompOpt(profile string) (string, error) {
+	if profile == "unconfined" {
+		return "seccomp=unconfined", nil
+	}
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read seccomp profile: %w", err)
+	}
+	if !json.Valid(data) {
+		return "", fmt.Errorf("invalid seccomp profile %s: not JSON", profile)
+	}
+	return "seccomp=" + string(data), nil
+}
+
+// checkNetwork reports a run that failed because go could not download
+// modules without a network, as no change to the generated tests can fix
+// that.
+func checkNetwork(cfg ExecutorConfig, result *agent.ExecutionResult) error {
+	if cfg.NetworkMode != "none" || result.ExitCode == 0 {
+		return nil
+	}
+	if !moduleDownloadFailure.MatchString(result.Stdout) && !moduleDownloadFailure.MatchString(result.Stderr) {
+		return nil
+	}
+	return errors.New("go could not download modules since the container has no network: vendor them, add them to the image or set the executor param network: \"bridge\"")
+}
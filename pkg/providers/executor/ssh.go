@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// remoteClientOpts returns the Docker client options for host. The Docker Go
+// client has no ssh transport of its own, so ssh:// hosts are reached the
+// same way the docker CLI does it: by running `docker system dial-stdio` on
+// the remote machine and speaking the API over the ssh session.
+func remoteClientOpts(host string) ([]client.Opt, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+	if u.Scheme != "ssh" {
+		return []client.Opt{client.WithHost(host)}, nil
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid docker host %q: missing hostname", host)
+	}
+
+	args := sshArgs(u)
+	return []client.Opt{
+		// The host is only used to build request URLs; the dialer ignores it.
+		client.WithHost("http://docker.example.com"),
+		client.WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialCommand("ssh", args...)
+		}),
+	}, nil
+}
+
+func sshArgs(u *url.URL) []string {
+	var args []string
+	if u.User != nil && u.User.Username() != "" {
+		args = append(args, "-l", u.User.Username())
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+}
+
+// dialCommand starts name with args and returns a connection over its stdin
+// and stdout. The command is not bound to the dial context because the HTTP
+// transport keeps connections alive beyond the request that opened them.
+func dialCommand(name string, args ...string) (net.Conn, error) {
+	cmd := exec.Command(name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+// commandConn adapts the stdio of a running command to net.Conn. Deadlines
+// are not supported; the command is killed when the connection is closed.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	once   sync.Once
+}
+
+func (c *commandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+func (c *commandConn) Close() error {
+	c.once.Do(func() {
+		c.stdin.Close()
+		if c.cmd.Process != nil {
+			c.cmd.Process.Kill()
+		}
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr                { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr               { return commandAddr{} }
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "command" }
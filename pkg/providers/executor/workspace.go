@@ -0,0 +1,207 @@
+package executor
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"localsprite/internal/agent"
+	"localsprite/internal/repocontext"
+)
+
+// workspaceMode selects how the local workspace directory reaches the
+// container.
+type workspaceMode int
+
+const (
+	// bindWorkspace bind-mounts the workspace; it only works when the Docker
+	// daemon shares the local filesystem.
+	bindWorkspace workspaceMode = iota
+
+	// copyWorkspace streams the workspace into the created container through
+	// the Docker copy API, which works against remote daemons.
+	copyWorkspace
+)
+
+// newWorkspace writes the generated files to a new temporary directory that
+// the caller removes. Unnamed files are written to testFilePattern in
+// ws.TestDir, i.e. the package under test when the project source is
+// included. Paths that would escape the workspace are rejected. It returns
+// the directory and the files with their resolved paths. Without files the
+// directory is empty, so that the project's own tests run as they are.
+func newWorkspace(ws agent.Workspace, testFilePattern string, files []agent.Artifact) (string, []agent.Artifact, error) {
+	if len(files) > 0 {
+		if err := agent.ValidateArtifacts(files); err != nil {
+			return "", nil, err
+		}
+	}
+	defaultPath := ws.TestFile(testFilePattern)
+	if !filepath.IsLocal(filepath.FromSlash(defaultPath)) {
+		return "", nil, fmt.Errorf("test file %q is outside the workspace", defaultPath)
+	}
+
+	tempDir, err := os.MkdirTemp("", "localsprite-test-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	resolved := make([]agent.Artifact, len(files))
+	for i, f := range files {
+		name := path.Clean(f.Path)
+		if f.Path == "" {
+			name = defaultPath
+		}
+		resolved[i] = agent.Artifact{Path: name, Content: f.Content}
+
+		p := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			os.RemoveAll(tempDir)
+			return "", nil, fmt.Errorf("failed to create dir for %s: %w", name, err)
+		}
+		if err := os.WriteFile(p, []byte(f.Content), 0644); err != nil {
+			os.RemoveAll(tempDir)
+			return "", nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return tempDir, resolved, nil
+}
+
+// tarWorkspace streams the contents of dir as a tar archive with paths
+// relative to dir. The daemon creates the container's working directory at
+// create time, so the archive can be extracted straight into it.
+//
+// If sourceDir is set, the files of that checkout that are not ignored by
+// git are archived first with their write permissions removed, so that the
+// tests cannot modify the originals; files in dir take precedence.
+func tarWorkspace(dir, sourceDir string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeWorkspaceTar(pw, dir, sourceDir))
+	}()
+	return pr
+}
+
+func writeWorkspaceTar(w io.Writer, dir, sourceDir string) error {
+	tw := tar.NewWriter(w)
+	dirs := map[string]bool{}
+
+	if sourceDir != "" {
+		if err := writeSourceTar(tw, dir, sourceDir, dirs); err != nil {
+			return fmt.Errorf("failed to archive source: %w", err)
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." || (d.IsDir() && dirs[filepath.ToSlash(rel)]) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		return writeTarEntry(tw, hdr, p)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive workspace: %w", err)
+	}
+
+	return tw.Close()
+}
+
+// writeSourceTar archives the files of sourceDir that dir does not replace,
+// recording the directories it creates in dirs.
+func writeSourceTar(tw *tar.Writer, dir, sourceDir string, dirs map[string]bool) error {
+	files, err := repocontext.ListFiles(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	var mkdir func(string) error
+	mkdir = func(d string) error {
+		if d == "." || dirs[d] {
+			return nil
+		}
+		if err := mkdir(path.Dir(d)); err != nil {
+			return err
+		}
+		dirs[d] = true
+		return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: d + "/", Mode: 0755})
+	}
+
+	for _, rel := range files {
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(rel))); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		p := filepath.Join(sourceDir, filepath.FromSlash(rel))
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		hdr.Mode &^= 0222
+
+		if err := mkdir(path.Dir(rel)); err != nil {
+			return err
+		}
+		if err := writeTarEntry(tw, hdr, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTarEntry writes hdr followed by the contents of the regular file p.
+func writeTarEntry(tw *tar.Writer, hdr *tar.Header, p string) error {
+	// Ownership of the local user means nothing inside the container
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
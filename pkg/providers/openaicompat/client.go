@@ -0,0 +1,156 @@
+package openaicompat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Message is a single chat message.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request is the body of a /chat/completions call.
+type Request struct {
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream"`
+}
+
+// ResponseFormat requests structured output, e.g. {"type": "json_object"}.
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type response struct {
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Response is the first choice of a chat completion.
+type Response struct {
+	Content          string
+	FinishReason     string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("chat completion failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls an OpenAI-compatible endpoint. Endpoint is the API base URL,
+// e.g. "http://imperial-construct:11434/v1".
+type Client struct {
+	Endpoint   string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for endpoint using http.DefaultClient.
+func NewClient(endpoint string) *Client {
+	return &Client{Endpoint: endpoint, HTTPClient: http.DefaultClient}
+}
+
+// ChatCompletion sends req and returns the first choice.
+func (c *Client) ChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	url := strings.TrimSuffix(c.Endpoint, "/") + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: errorMessage(respBody)}
+	}
+
+	var parsed response
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return nil, fmt.Errorf("chat completion returned no choices")
+	}
+
+	choice := parsed.Choices[0]
+	return &Response{
+		Content:          choice.Message.Content,
+		FinishReason:     choice.FinishReason,
+		PromptTokens:     parsed.Usage.PromptTokens,
+		CompletionTokens: parsed.Usage.CompletionTokens,
+	}, nil
+}
+
+// errorMessage extracts the error text from the OpenAI ({"error": {"message":
+// ...}}) and Ollama ({"error": "..."}) error shapes, falling back to the raw
+// body.
+func errorMessage(body []byte) string {
+	var wrapped struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil && len(wrapped.Error) > 0 {
+		var obj struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(wrapped.Error, &obj); err == nil && obj.Message != "" {
+			return obj.Message
+		}
+		var str string
+		if err := json.Unmarshal(wrapped.Error, &str); err == nil && str != "" {
+			return str
+		}
+	}
+
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 512 {
+		msg = msg[:512] + "..."
+	}
+	if msg == "" {
+		msg = "empty response body"
+	}
+	return msg
+}
@@ -1,19 +1,218 @@
 package planner
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"localsprite/internal/agent"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com"
+
+var (
+	// ErrGeminiBlocked is returned when Gemini refuses the prompt or stops
+	// the response for safety or policy reasons.
+	ErrGeminiBlocked = errors.New("gemini blocked the request")
+
+	// ErrGeminiQuota is returned when the API key has exhausted its quota or
+	// rate limit.
+	ErrGeminiQuota = errors.New("gemini quota exceeded")
 )
 
 type GeminiPlanner struct {
-	Model string
+	Model  string
+	APIKey string
+
+	// BaseURL of the Gemini API (default: https://generativelanguage.googleapis.com)
+	BaseURL string
+
+	HTTPClient *http.Client
 }
 
 func NewGeminiPlanner(model string) *GeminiPlanner {
-	return &GeminiPlanner{Model: model}
+	return &GeminiPlanner{
+		Model:      model,
+		BaseURL:    geminiBaseURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// GeminiError is returned for non-2xx responses from the Gemini API.
+type GeminiError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *GeminiError) Error() string {
+	return fmt.Sprintf("gemini API error %d (%s): %s", e.StatusCode, e.Status, e.Message)
+}
+
+// Unwrap maps quota errors to ErrGeminiQuota.
+func (e *GeminiError) Unwrap() error {
+	if e.StatusCode == http.StatusTooManyRequests || e.Status == "RESOURCE_EXHAUSTED" {
+		return ErrGeminiQuota
+	}
+	return nil
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent `json:"contents"`
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	GenerationConfig  struct {
+		ResponseMimeType string          `json:"responseMimeType,omitempty"`
+		ResponseSchema   json.RawMessage `json:"responseSchema,omitempty"`
+	} `json:"generationConfig"`
 }
 
-func (g *GeminiPlanner) Plan(repoContext string) (string, error) {
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+}
+
+// planSchema constrains Gemini's JSON output to agent.TestPlan. Gemini uses
+// the OpenAPI subset with upper-case type names rather than JSON Schema.
+const planSchema = `{
+  "type": "OBJECT",
+  "properties": {
+    "summary": {"type": "STRING"},
+    "framework": {"type": "STRING"},
+    "target_files": {"type": "ARRAY", "items": {"type": "STRING"}},
+    "scenarios": {
+      "type": "ARRAY",
+      "items": {
+        "type": "OBJECT",
+        "properties": {
+          "name": {"type": "STRING"},
+          "description": {"type": "STRING"},
+          "target_file": {"type": "STRING"},
+          "function": {"type": "STRING"},
+          "priority": {"type": "STRING", "enum": ["high", "medium", "low"]},
+          "edge_cases": {"type": "ARRAY", "items": {"type": "STRING"}},
+          "assertions": {"type": "ARRAY", "items": {"type": "STRING"}}
+        },
+        "required": ["name", "description"]
+      }
+    }
+  },
+  "required": ["summary", "scenarios"]
+}`
+
+func (g *GeminiPlanner) Plan(ctx context.Context, repoContext string) (*agent.TestPlan, error) {
 	fmt.Printf("[Planner] Gemini (%s) is analyzing repository context...\n", g.Model)
-	// Integration with Google GenAI SDK would go here.
-	return "Test Plan: Cover edge cases in auth module", nil
-}
\ No newline at end of file
+
+	var reqBody geminiRequest
+	reqBody.Contents = []geminiContent{{Role: "user", Parts: []geminiPart{{Text: planPrompt(repoContext)}}}}
+	reqBody.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: systemPrompt}}}
+	reqBody.GenerationConfig.ResponseMimeType = "application/json"
+	reqBody.GenerationConfig.ResponseSchema = json.RawMessage(planSchema)
+
+	text, err := g.generate(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := agent.ParseTestPlan([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("gemini %s: %w", g.Model, err)
+	}
+	return plan, nil
+}
+
+// generate calls generateContent and returns the text of the first candidate.
+func (g *GeminiPlanner) generate(ctx context.Context, reqBody geminiRequest) (string, error) {
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent", strings.TrimSuffix(g.BaseURL, "/"), g.Model)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", g.APIKey)
+
+	httpClient := g.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", geminiError(resp.StatusCode, respBody)
+	}
+
+	var parsed geminiResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if reason := parsed.PromptFeedback.BlockReason; reason != "" {
+		return "", fmt.Errorf("%w: prompt blocked (%s)", ErrGeminiBlocked, reason)
+	}
+	if len(parsed.Candidates) == 0 {
+		return "", fmt.Errorf("gemini %s: response contained no candidates", g.Model)
+	}
+
+	candidate := parsed.Candidates[0]
+	switch candidate.FinishReason {
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "", fmt.Errorf("%w: response stopped (%s)", ErrGeminiBlocked, candidate.FinishReason)
+	case "MAX_TOKENS":
+		return "", fmt.Errorf("gemini %s: response truncated at the output token limit", g.Model)
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String(), nil
+}
+
+func geminiError(status int, body []byte) *GeminiError {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	apiErr := &GeminiError{StatusCode: status}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error.Message != "" {
+		apiErr.Status = parsed.Error.Status
+		apiErr.Message = parsed.Error.Message
+	} else {
+		apiErr.Status = http.StatusText(status)
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
@@ -0,0 +1,121 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestGeminiPlanner(url string) *GeminiPlanner {
+	p := NewGeminiPlanner("gemini-test")
+	p.APIKey = "test-key"
+	p.BaseURL = url
+	return p
+}
+
+func TestGeminiPlanner_Plan(t *testing.T) {
+	var got geminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:generateContent" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("expected api key header")
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+
+		plan := `{"summary":"Cover auth","target_files":["auth.go"],"scenarios":[{"name":"bad password","description":"Login rejects a wrong password","function":"Login","priority":"high","assertions":["returns ErrUnauthorized"]}]}`
+		resp, _ := json.Marshal(map[string]any{
+			"candidates": []any{map[string]any{
+				"content":      map[string]any{"parts": []any{map[string]any{"text": plan}}},
+				"finishReason": "STOP",
+			}},
+		})
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	plan, err := newTestGeminiPlanner(server.URL).Plan(context.Background(), "package auth")
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.Scenarios) != 1 || plan.Scenarios[0].Function != "Login" || plan.Scenarios[0].Priority != "high" {
+		t.Errorf("expected structured plan, got %+v", plan)
+	}
+	if got.GenerationConfig.ResponseMimeType != "application/json" || len(got.GenerationConfig.ResponseSchema) == 0 {
+		t.Errorf("expected JSON mode with a schema, got %+v", got.GenerationConfig)
+	}
+	if !strings.Contains(got.Contents[0].Parts[0].Text, "package auth") {
+		t.Errorf("expected repo context in prompt")
+	}
+}
+
+func TestGeminiPlanner_SafetyBlock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"promptFeedback":{"blockReason":"SAFETY"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestGeminiPlanner(server.URL).Plan(context.Background(), "ctx")
+	if !errors.Is(err, ErrGeminiBlocked) {
+		t.Errorf("expected ErrGeminiBlocked, got %v", err)
+	}
+}
+
+func TestGeminiPlanner_CandidateSafetyStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestGeminiPlanner(server.URL).Plan(context.Background(), "ctx")
+	if !errors.Is(err, ErrGeminiBlocked) {
+		t.Errorf("expected ErrGeminiBlocked, got %v", err)
+	}
+}
+
+func TestGeminiPlanner_Quota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"code":429,"message":"Resource has been exhausted (e.g. check quota).","status":"RESOURCE_EXHAUSTED"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestGeminiPlanner(server.URL).Plan(context.Background(), "ctx")
+	if !errors.Is(err, ErrGeminiQuota) {
+		t.Errorf("expected ErrGeminiQuota, got %v", err)
+	}
+	var apiErr *GeminiError
+	if !errors.As(err, &apiErr) || apiErr.Status != "RESOURCE_EXHAUSTED" {
+		t.Errorf("expected GeminiError with status, got %v", err)
+	}
+}
+
+func TestGeminiPlanner_InvalidKeyIsNotQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"API key not valid.","status":"INVALID_ARGUMENT"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestGeminiPlanner(server.URL).Plan(context.Background(), "ctx")
+	if err == nil || errors.Is(err, ErrGeminiQuota) || !strings.Contains(err.Error(), "API key not valid") {
+		t.Errorf("expected invalid key error, got %v", err)
+	}
+}
+
+func TestGeminiPlanner_InvalidPlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"{\"summary\":\"nothing\",\"scenarios\":[]}"}]},"finishReason":"STOP"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestGeminiPlanner(server.URL).Plan(context.Background(), "ctx")
+	if err == nil || !strings.Contains(err.Error(), "no scenarios") {
+		t.Errorf("expected invalid plan error, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"localsprite/internal/agent"
+	"localsprite/pkg/providers/openaicompat"
+)
+
+type LocalLLMPlanner struct {
+	Endpoint string
+	Model    string
+
+	// Client talks to the OpenAI-compatible endpoint (e.g. Ollama's /v1)
+	Client *openaicompat.Client
+}
+
+func NewLocalLLMPlanner(endpoint, model string) *LocalLLMPlanner {
+	return &LocalLLMPlanner{
+		Endpoint: endpoint,
+		Model:    model,
+		Client:   openaicompat.NewClient(endpoint),
+	}
+}
+
+func (l *LocalLLMPlanner) Plan(ctx context.Context, repoContext string) (*agent.TestPlan, error) {
+	fmt.Printf("[Planner] Local LLM (%s at %s) is analyzing repository context...\n", l.Model, l.Endpoint)
+
+	resp, err := l.Client.ChatCompletion(ctx, openaicompat.Request{
+		Model: l.Model,
+		Messages: []openaicompat.Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: planPrompt(repoContext)},
+		},
+		ResponseFormat: &openaicompat.ResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local LLM %s: %w", l.Model, err)
+	}
+	if resp.FinishReason == "length" {
+		return nil, fmt.Errorf("local LLM %s: plan truncated at the model's output limit", l.Model)
+	}
+
+	plan, err := agent.ParseTestPlan([]byte(resp.Content))
+	if err != nil {
+		return nil, fmt.Errorf("local LLM %s: %w", l.Model, err)
+	}
+	return plan, nil
+}
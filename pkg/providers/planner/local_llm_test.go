@@ -0,0 +1,58 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"localsprite/pkg/providers/openaicompat"
+)
+
+func TestLocalLLMPlanner_Plan(t *testing.T) {
+	var got openaicompat.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("expected /v1/chat/completions, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		plan := "```json\n" + `{"summary":"auth","scenarios":[{"name":"bad password","description":"Login rejects a wrong password"}]}` + "\n```"
+		resp, _ := json.Marshal(map[string]any{
+			"choices": []any{map[string]any{
+				"message":       map[string]any{"content": plan},
+				"finish_reason": "stop",
+			}},
+		})
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	p := NewLocalLLMPlanner(server.URL+"/v1", "gemma3:12b")
+	plan, err := p.Plan(context.Background(), "package auth\nfunc Login(user, pass string) error")
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	if len(plan.Scenarios) != 1 || plan.Scenarios[0].Name != "bad password" {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" {
+		t.Errorf("expected JSON response format, got %+v", got.ResponseFormat)
+	}
+	if got.Model != "gemma3:12b" || !strings.Contains(got.Messages[1].Content, "func Login") {
+		t.Errorf("expected model and repo context in request, got %+v", got)
+	}
+}
+
+func TestLocalLLMPlanner_InvalidPlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"1. Test Login with a bad password"}}]}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewLocalLLMPlanner(server.URL, "gemma").Plan(context.Background(), "ctx"); err == nil {
+		t.Error("expected error for a plan that is not JSON")
+	}
+}
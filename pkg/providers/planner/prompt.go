@@ -0,0 +1,25 @@
+package planner
+
+import (
+	"fmt"
+
+	"localsprite/internal/agent"
+)
+
+// systemPrompt is sent to every planner model.
+const systemPrompt = `You are a senior QA engineer. Given context about a software repository,
+write a concise, actionable test plan: the functions or pages to test, the scenarios
+and edge cases to cover, and the assertions each test should make.
+Respond with a single JSON object and nothing else.`
+
+// planPrompt builds the user prompt for planning tests from repository context.
+func planPrompt(repoContext string) string {
+	return fmt.Sprintf(`Repository context:
+%s
+
+Write the test plan as JSON matching this schema:
+%s
+
+Give every scenario a unique name, the target_file and function it covers where
+applicable, and a priority of "high", "medium" or "low".`, repoContext, agent.TestPlanJSONSchema)
+}